@@ -0,0 +1,163 @@
+package account
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Account struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Created     string `json:"created"`
+	LastLogin   string `json:"lastLogin"`
+}
+
+var (
+	mu       sync.RWMutex
+	accounts map[string]*Account
+)
+
+func storePath() string {
+	return filepath.Join("config", "accounts.json")
+}
+
+// load reads the account store from disk the first time it is needed.
+// Callers must hold mu for writing.
+func load() {
+	if accounts != nil {
+		return
+	}
+	accounts = make(map[string]*Account)
+	data, err := os.ReadFile(storePath())
+	if err != nil {
+		return
+	}
+	var list []*Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return
+	}
+	for _, a := range list {
+		accounts[a.ID] = a
+	}
+}
+
+func save() error {
+	list := make([]*Account, 0, len(accounts))
+	for _, a := range accounts {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	bytes, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(storePath()), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(storePath(), bytes, 0644)
+}
+
+// Record stores an account that just logged in, creating it on first sight.
+func Record(id, displayName string) (*Account, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	a := accounts[id]
+	if a == nil {
+		a = &Account{ID: id, Created: now}
+		accounts[id] = a
+	}
+	a.DisplayName = displayName
+	a.LastLogin = now
+
+	copied := *a
+	return &copied, save()
+}
+
+func Get(id string) *Account {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+
+	a := accounts[id]
+	if a == nil {
+		return nil
+	}
+	copied := *a
+	return &copied
+}
+
+// FindByDisplayName does a case-insensitive exact lookup.
+func FindByDisplayName(name string) *Account {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+
+	for _, a := range accounts {
+		if strings.EqualFold(a.DisplayName, name) {
+			copied := *a
+			return &copied
+		}
+	}
+	return nil
+}
+
+type SearchMatch struct {
+	Account *Account
+	Exact   bool
+	Pinned  bool
+}
+
+// Search returns accounts whose display name starts with prefix, pinned
+// accounts (pinned may be nil) first, then exact matches, then by name.
+// The account excludeId is left out of the results.
+func Search(prefix, excludeId string, limit int, pinned func(id string) bool) []SearchMatch {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+
+	lower := strings.ToLower(prefix)
+	var matches []SearchMatch
+	for _, a := range accounts {
+		if a.ID == excludeId {
+			continue
+		}
+		name := strings.ToLower(a.DisplayName)
+		if !strings.HasPrefix(name, lower) {
+			continue
+		}
+		copied := *a
+		matches = append(matches, SearchMatch{
+			Account: &copied,
+			Exact:   name == lower,
+			Pinned:  pinned != nil && pinned(a.ID),
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Exact != b.Exact {
+			return a.Exact
+		}
+		if len(a.Account.DisplayName) != len(b.Account.DisplayName) {
+			return len(a.Account.DisplayName) < len(b.Account.DisplayName)
+		}
+		return strings.ToLower(a.Account.DisplayName) < strings.ToLower(b.Account.DisplayName)
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
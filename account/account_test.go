@@ -0,0 +1,37 @@
+package account
+
+import "testing"
+
+func TestSearchOrder(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for id, name := range map[string]string{
+		"me":      "Me",
+		"exact":   "neo",
+		"friend":  "NeoFriend",
+		"other":   "NeoOther",
+		"longer":  "NeoOtherLonger",
+		"nomatch": "Someone",
+	} {
+		if _, err := Record(id, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches := Search("Neo", "me", 0, func(id string) bool { return id == "friend" })
+	var got []string
+	for _, m := range matches {
+		got = append(got, m.Account.ID)
+	}
+	want := []string{"friend", "exact", "other", "longer"}
+	if len(got) != len(want) {
+		t.Fatalf("Search = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Search = %v, want %v", got, want)
+		}
+	}
+	if !matches[1].Exact || matches[0].Exact {
+		t.Errorf("Exact flags = %v, %v", matches[0].Exact, matches[1].Exact)
+	}
+}
@@ -0,0 +1,226 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"neonite-go/structs"
+)
+
+// Lifetimes of the tokens the Fortnite login issues.
+const (
+	AccessTokenLifetime  = 8 * time.Hour
+	RefreshTokenLifetime = 32 * time.Hour
+)
+
+// grant is an issued token: whose it is and until when it works.
+type grant struct {
+	AccountID string    `json:"accountId"`
+	Expires   time.Time `json:"expires"`
+}
+
+// tokenFile is the token store as saved in config/tokens.json, so clients
+// stay signed in across restarts.
+type tokenFile struct {
+	Access  map[string]grant `json:"access"`
+	Refresh map[string]grant `json:"refresh"`
+}
+
+var (
+	tokenMu      sync.Mutex
+	tokens       tokenFile
+	tokensLoaded bool
+)
+
+func tokenPath() string {
+	return filepath.Join("config", "tokens.json")
+}
+
+// loadTokens reads the token store the first time it is needed. Callers
+// must hold tokenMu.
+func loadTokens() {
+	if tokensLoaded {
+		return
+	}
+	tokensLoaded = true
+	if data, err := os.ReadFile(tokenPath()); err == nil {
+		if err := json.Unmarshal(data, &tokens); err != nil {
+			structs.NeoLog("[Account] Ignoring " + tokenPath() + ": " + err.Error())
+			tokens = tokenFile{}
+		}
+	}
+	if tokens.Access == nil {
+		tokens.Access = make(map[string]grant)
+	}
+	if tokens.Refresh == nil {
+		tokens.Refresh = make(map[string]grant)
+	}
+}
+
+// saveTokens drops expired tokens and writes the rest out. Callers must
+// hold tokenMu.
+func saveTokens() {
+	now := time.Now()
+	for _, m := range []map[string]grant{tokens.Access, tokens.Refresh} {
+		for token, g := range m {
+			if now.After(g.Expires) {
+				delete(m, token)
+			}
+		}
+	}
+	bytes, err := json.MarshalIndent(tokens, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(tokenPath()), os.ModePerm)
+	}
+	if err == nil {
+		err = os.WriteFile(tokenPath(), bytes, 0600)
+	}
+	if err != nil {
+		structs.NeoLog("[Account] Could not save tokens: " + err.Error())
+	}
+}
+
+func owner(m map[string]grant, token string) (string, bool) {
+	g, ok := m[token]
+	if !ok || time.Now().After(g.Expires) {
+		return "", false
+	}
+	return g.AccountID, true
+}
+
+// StoreToken remembers which account an issued access token belongs to
+// and for how long it works.
+func StoreToken(token, accountId string, lifetime time.Duration) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	loadTokens()
+	tokens.Access[token] = grant{AccountID: accountId, Expires: time.Now().Add(lifetime).UTC()}
+	saveTokens()
+}
+
+// TokenOwner returns the account an unexpired access token was issued to.
+func TokenOwner(token string) (string, bool) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	loadTokens()
+	return owner(tokens.Access, token)
+}
+
+func RevokeToken(token string) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	loadTokens()
+	delete(tokens.Access, token)
+	saveTokens()
+}
+
+// RevokeAccountTokens drops every access and refresh token issued to an
+// account.
+func RevokeAccountTokens(accountId string) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	loadTokens()
+	for _, m := range []map[string]grant{tokens.Access, tokens.Refresh} {
+		for token, g := range m {
+			if g.AccountID == accountId {
+				delete(m, token)
+			}
+		}
+	}
+	saveTokens()
+}
+
+// BearerToken extracts the token from an "Authorization: bearer ..." header.
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return header[7:]
+	}
+	return ""
+}
+
+// Caller returns the account behind the request's bearer token, if this
+// server issued it and it has not expired.
+func Caller(r *http.Request) (string, bool) {
+	return TokenOwner(BearerToken(r))
+}
+
+// NewToken returns a random token for any of the stores below.
+func NewToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// StoreRefreshToken remembers which account a refresh token belongs to and
+// for how long it works.
+func StoreRefreshToken(token, accountId string, lifetime time.Duration) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	loadTokens()
+	tokens.Refresh[token] = grant{AccountID: accountId, Expires: time.Now().Add(lifetime).UTC()}
+	saveTokens()
+}
+
+// RefreshTokenOwner returns the account an unexpired refresh token was
+// issued to.
+func RefreshTokenOwner(token string) (string, bool) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	loadTokens()
+	return owner(tokens.Refresh, token)
+}
+
+// exchangeCodeLifetime is how long an exchange code can be redeemed.
+const exchangeCodeLifetime = 5 * time.Minute
+
+type exchangeCode struct {
+	accountId string
+	expires   time.Time
+}
+
+var (
+	exchangeMu    sync.Mutex
+	exchangeCodes = make(map[string]exchangeCode)
+)
+
+// NewExchangeCode issues a one time code that signs in as the account,
+// which the client hands to the EOS login.
+func NewExchangeCode(accountId string) (string, time.Duration) {
+	exchangeMu.Lock()
+	defer exchangeMu.Unlock()
+
+	now := time.Now()
+	for code, c := range exchangeCodes {
+		if now.After(c.expires) {
+			delete(exchangeCodes, code)
+		}
+	}
+	code := NewToken()
+	exchangeCodes[code] = exchangeCode{accountId: accountId, expires: now.Add(exchangeCodeLifetime)}
+	return code, exchangeCodeLifetime
+}
+
+// RedeemExchangeCode returns the account behind an exchange code issued by
+// NewExchangeCode. Each code works once.
+func RedeemExchangeCode(code string) (string, bool) {
+	exchangeMu.Lock()
+	defer exchangeMu.Unlock()
+
+	c, ok := exchangeCodes[code]
+	if !ok {
+		return "", false
+	}
+	delete(exchangeCodes, code)
+	if time.Now().After(c.expires) {
+		return "", false
+	}
+	return c.accountId, true
+}
@@ -0,0 +1,40 @@
+package account
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokensPersistAndExpire(t *testing.T) {
+	t.Chdir(t.TempDir())
+	tokenMu.Lock()
+	tokens, tokensLoaded = tokenFile{}, false
+	tokenMu.Unlock()
+
+	StoreToken("live", "alice", time.Hour)
+	StoreToken("stale", "alice", -time.Second)
+	StoreRefreshToken("refresh", "alice", time.Hour)
+
+	// Read the store back as after a restart.
+	tokenMu.Lock()
+	tokens, tokensLoaded = tokenFile{}, false
+	tokenMu.Unlock()
+
+	if owner, ok := TokenOwner("live"); !ok || owner != "alice" {
+		t.Errorf("TokenOwner(live) = %q, %v", owner, ok)
+	}
+	if _, ok := TokenOwner("stale"); ok {
+		t.Error("expired token still works")
+	}
+	if owner, ok := RefreshTokenOwner("refresh"); !ok || owner != "alice" {
+		t.Errorf("RefreshTokenOwner(refresh) = %q, %v", owner, ok)
+	}
+
+	RevokeAccountTokens("alice")
+	if _, ok := TokenOwner("live"); ok {
+		t.Error("revoked token still works")
+	}
+	if _, ok := RefreshTokenOwner("refresh"); ok {
+		t.Error("revoked refresh token still works")
+	}
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"neonite-go/account"
+	"neonite-go/party"
+	"neonite-go/routes/xmpp"
+	"neonite-go/structs"
+)
+
+const (
+	// AccountID is the lobby bot's account. The members it adds to parties
+	// get ids made from it, see newBotID.
+	AccountID = "NeoniteBot"
+	Resource  = "V2:Fortnite:WIN::NeoniteBot"
+)
+
+// JID is the full JID players chat with.
+func JID() string {
+	return fmt.Sprintf("%s@%s/%s", AccountID, xmpp.Domain, Resource)
+}
+
+// IsBot reports whether the account is the lobby bot or one of the members
+// it added to a party. Real accounts never are, whatever their id.
+func IsBot(accountId string) bool {
+	if accountId == AccountID {
+		return true
+	}
+	membersMu.Lock()
+	defer membersMu.Unlock()
+	_, ok := owners[accountId]
+	return ok
+}
+
+func DisplayName() string {
+	if structs.Settings.Bot.DisplayName != "" {
+		return structs.Settings.Bot.DisplayName
+	}
+	return AccountID
+}
+
+// conn is the bot's always connected XMPP session. Stanzas routed to it are
+// handled in process instead of being written to a socket.
+type conn struct{}
+
+func (conn) AccountID() string { return AccountID }
+func (conn) JID() string       { return JID() }
+
+func (conn) Send(stanza string) error {
+	e, err := xmpp.ParseElement([]byte(stanza))
+	if err != nil {
+		return err
+	}
+	if e.Local() != "message" || e.Attr("type") == "groupchat" || e.Attr("type") == "error" {
+		return nil
+	}
+	from := e.Attr("from")
+	body := strings.TrimSpace(e.ChildText("body"))
+	if from == "" || body == "" {
+		return nil
+	}
+
+	handleChat(Chat{AccountID: xmpp.AccountOf(from), JID: from}, body)
+	return nil
+}
+
+// Presence is what friends see for the bot.
+func (conn) Presence() *xmpp.Element {
+	status, _ := json.Marshal(map[string]interface{}{
+		"Status":           structs.Settings.Bot.Status,
+		"bIsPlaying":       false,
+		"bIsJoinable":      false,
+		"bHasVoiceSupport": false,
+		"SessionId":        "",
+		"ProductName":      "Fortnite",
+		"Properties": map[string]interface{}{
+			"KairosProfile_j": map[string]interface{}{
+				"appInstalled":     "init",
+				"avatar":           strings.ToLower(structs.Settings.Bot.Character),
+				"avatarBackground": "[]",
+			},
+		},
+	})
+	return &xmpp.Element{
+		Name:     "presence",
+		Children: []*xmpp.Element{{Name: "status", Text: string(status)}},
+	}
+}
+
+// Start brings the bot online and starts removing its party members once
+// the player who added them leaves.
+func Start() {
+	if _, err := account.Record(AccountID, DisplayName()); err != nil {
+		structs.NeoLog("[Bot] Failed to record account: " + err.Error())
+	}
+	xmpp.Register(conn{})
+	party.Subscribe(onPartyEvent)
+	structs.NeoLog("[Bot] " + JID() + " online")
+}
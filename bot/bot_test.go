@@ -0,0 +1,25 @@
+package bot
+
+import "testing"
+
+func TestIsBot(t *testing.T) {
+	track("bot-test-owner", "NeoniteBot41")
+	defer forget("NeoniteBot41")
+
+	tests := []struct {
+		accountId string
+		want      bool
+	}{
+		{AccountID, true},
+		{"NeoniteBot41", true},
+		{"NeoniteBot42", false},
+		{"NeoniteBotFan", false},
+		{"neonitebot", false},
+		{"someone", false},
+	}
+	for _, tt := range tests {
+		if got := IsBot(tt.accountId); got != tt.want {
+			t.Errorf("IsBot(%q) = %v, want %v", tt.accountId, got, tt.want)
+		}
+	}
+}
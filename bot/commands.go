@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"neonite-go/party"
+	"neonite-go/routes/xmpp"
+)
+
+// Chat is the sender of one chat message to the bot.
+type Chat struct {
+	AccountID string
+	JID       string
+}
+
+// Reply sends a chat message back to the sender's session.
+func (c Chat) Reply(format string, args ...interface{}) {
+	msg := &xmpp.Element{Name: "message"}
+	msg.SetAttr("from", JID())
+	msg.SetAttr("type", "chat")
+	msg.Children = []*xmpp.Element{{Name: "body", Text: fmt.Sprintf(format, args...)}}
+	xmpp.Route(c.JID, msg)
+}
+
+// Command is a chat command. Run gets the text after the command name.
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(c Chat, args string)
+}
+
+var (
+	commandsMu sync.RWMutex
+	commands   = make(map[string]Command)
+)
+
+// RegisterCommand adds a command, replacing one with the same name.
+func RegisterCommand(cmd Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	commands[strings.ToLower(cmd.Name)] = cmd
+}
+
+func init() {
+	RegisterCommand(Command{
+		Name:        "help",
+		Usage:       "help",
+		Description: "list the commands",
+		Run:         helpCommand,
+	})
+	RegisterCommand(Command{
+		Name:        "gift",
+		Usage:       "gift <name>",
+		Description: "gift yourself a cosmetic",
+		Run:         giftCommand,
+	})
+	RegisterCommand(Command{
+		Name:        "copy",
+		Usage:       "copy [name]",
+		Description: "wear what a party member is wearing",
+		Run:         copyCommand,
+	})
+	RegisterCommand(Command{
+		Name:        "addbot",
+		Usage:       "addbot [count]",
+		Description: "add bots to your party",
+		Run:         addBotCommand,
+	})
+	RegisterCommand(Command{
+		Name:        "add4bot",
+		Usage:       "add4bot",
+		Description: "add four bots to your party",
+		Run:         func(c Chat, _ string) { addBots(c, 4) },
+	})
+	RegisterCommand(Command{
+		Name:        "maxbot",
+		Usage:       "maxbot",
+		Description: "fill your party with bots",
+		Run:         func(c Chat, _ string) { addBots(c, party.DefaultMaxSize) },
+	})
+	RegisterCommand(Command{
+		Name:        "removebots",
+		Usage:       "removebots",
+		Description: "remove your bots from your party",
+		Run:         removeBotsCommand,
+	})
+}
+
+// PromptResult tells the bot what to do after a prompt saw a message.
+type PromptResult int
+
+const (
+	PromptDone PromptResult = iota // answered, stop waiting
+	PromptWait                     // keep waiting for another answer
+	PromptSkip                     // not an answer, stop waiting and run it as a command
+)
+
+type prompt struct {
+	handle  func(c Chat, body string) PromptResult
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+var (
+	promptsMu sync.Mutex
+	prompts   = make(map[string]*prompt)
+)
+
+// Prompt hands the sender's next messages to fn instead of the command
+// parser. It ends when fn is done, when another prompt replaces it, or when
+// timeout passes without a message, in which case onTimeout runs.
+func (c Chat) Prompt(timeout time.Duration, fn func(c Chat, body string) PromptResult, onTimeout func(c Chat)) {
+	p := &prompt{handle: fn, timeout: timeout}
+	p.timer = time.AfterFunc(timeout, func() {
+		if endPrompt(c.AccountID, p) && onTimeout != nil {
+			onTimeout(c)
+		}
+	})
+
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	if old := prompts[c.AccountID]; old != nil {
+		old.timer.Stop()
+	}
+	prompts[c.AccountID] = p
+}
+
+// endPrompt removes p if it is still the account's prompt.
+func endPrompt(accountId string, p *prompt) bool {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	if prompts[accountId] != p {
+		return false
+	}
+	p.timer.Stop()
+	delete(prompts, accountId)
+	return true
+}
+
+// handleChat passes a chat message to a pending prompt, or else runs the
+// command in it. The leading "!" the old bot required is optional.
+func handleChat(c Chat, body string) {
+	promptsMu.Lock()
+	p := prompts[c.AccountID]
+	promptsMu.Unlock()
+
+	if p != nil {
+		switch p.handle(c, body) {
+		case PromptWait:
+			// Only a prompt that has not ended in the meantime keeps waiting.
+			promptsMu.Lock()
+			if prompts[c.AccountID] == p {
+				p.timer.Reset(p.timeout)
+			}
+			promptsMu.Unlock()
+			return
+		case PromptDone:
+			endPrompt(c.AccountID, p)
+			return
+		default:
+			endPrompt(c.AccountID, p)
+		}
+	}
+
+	name, args, _ := strings.Cut(strings.TrimPrefix(body, "!"), " ")
+
+	commandsMu.RLock()
+	cmd, ok := commands[strings.ToLower(name)]
+	commandsMu.RUnlock()
+
+	if !ok {
+		c.Reply("Unknown command %q, send help for a list.", name)
+		return
+	}
+	cmd.Run(c, strings.TrimSpace(args))
+}
+
+func helpCommand(c Chat, _ string) {
+	commandsMu.RLock()
+	lines := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		lines = append(lines, cmd.Usage+" - "+cmd.Description)
+	}
+	commandsMu.RUnlock()
+
+	sort.Strings(lines)
+	c.Reply("Commands:\n%s", strings.Join(lines, "\n"))
+}
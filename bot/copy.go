@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+
+	"neonite-go/account"
+	"neonite-go/locker"
+	"neonite-go/party"
+)
+
+func memberName(m *party.Member) string {
+	if dn := m.Meta["urn:epic:member:dn_s"]; dn != "" {
+		return dn
+	}
+	if a := account.Get(m.AccountID); a != nil {
+		return a.DisplayName
+	}
+	return m.AccountID
+}
+
+// findMember picks the party member to copy. Without a name it takes the
+// only other player, or the only other member at all.
+func findMember(p *party.Party, self, name string) (*party.Member, []string) {
+	var others, players, matches []*party.Member
+	for _, m := range p.Members {
+		if m.AccountID == self {
+			continue
+		}
+		others = append(others, m)
+		if !IsBot(m.AccountID) {
+			players = append(players, m)
+		}
+		if name != "" && (strings.EqualFold(memberName(m), name) || strings.EqualFold(m.AccountID, name)) {
+			return m, nil
+		}
+		if name != "" && strings.HasPrefix(strings.ToLower(memberName(m)), strings.ToLower(name)) {
+			matches = append(matches, m)
+		}
+	}
+
+	switch {
+	case name != "" && len(matches) == 1:
+		return matches[0], nil
+	case name == "" && len(players) == 1:
+		return players[0], nil
+	case name == "" && len(others) == 1:
+		return others[0], nil
+	}
+
+	candidates := others
+	if len(matches) > 0 {
+		candidates = matches
+	}
+	names := make([]string, len(candidates))
+	for i, m := range candidates {
+		names[i] = memberName(m)
+	}
+	return nil, names
+}
+
+func copyCommand(c Chat, args string) {
+	p := party.GetUserParty(c.AccountID)
+	if p == nil {
+		c.Reply("You are not in a party.")
+		return
+	}
+
+	target, names := findMember(p, c.AccountID, args)
+	if target == nil {
+		if len(names) == 0 {
+			c.Reply("There is nobody in your party to copy.")
+		} else {
+			c.Reply("Copy who? Send copy <name> with one of: %s", strings.Join(names, ", "))
+		}
+		return
+	}
+
+	loadout, err := locker.MemberLoadout(p, target.AccountID)
+	if err != nil {
+		if errors.Is(err, locker.ErrNoLoadout) {
+			c.Reply("%s has no loadout to copy.", memberName(target))
+		} else {
+			c.Reply("Could not copy %s: %s", memberName(target), err.Error())
+		}
+		return
+	}
+
+	result, err := locker.Copy(c.AccountID, loadout)
+	switch {
+	case err != nil:
+		c.Reply("Could not copy %s: %s", memberName(target), err.Error())
+	case len(result.Equipped) == 0:
+		c.Reply("You do not own anything %s is wearing.", memberName(target))
+	case len(result.Skipped) > 0:
+		c.Reply("Copied %s's loadout, leaving out %d items you do not own. Reopen your locker to see it.", memberName(target), len(result.Skipped))
+	default:
+		c.Reply("Copied %s's loadout. Reopen your locker to see it.", memberName(target))
+	}
+}
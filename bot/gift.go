@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonite-go/cosmetics"
+	"neonite-go/profile"
+)
+
+const (
+	giftPageSize = 5
+	giftTimeout  = time.Minute
+	giftMessage  = "Thanks for using Neonite"
+)
+
+func giftCommand(c Chat, args string) {
+	if args == "" {
+		c.Reply("Usage: gift <name>")
+		return
+	}
+
+	matches := cosmetics.Search(args)
+	if len(matches) > 1 && matches[0].NameIs(args) && !matches[1].NameIs(args) {
+		matches = matches[:1]
+	}
+	switch len(matches) {
+	case 0:
+		c.Reply("No cosmetic matches %q.", args)
+	case 1:
+		sendGift(c, matches[0])
+	default:
+		page := 0
+		showGiftPage(c, matches, page)
+		c.Prompt(giftTimeout, func(c Chat, body string) PromptResult {
+			return pickGift(c, matches, &page, body)
+		}, func(c Chat) {
+			c.Reply("Gift selection timed out.")
+		})
+	}
+}
+
+func giftPages(matches []cosmetics.Cosmetic) int {
+	return (len(matches) + giftPageSize - 1) / giftPageSize
+}
+
+func showGiftPage(c Chat, matches []cosmetics.Cosmetic, page int) {
+	start := page * giftPageSize
+	end := min(start+giftPageSize, len(matches))
+
+	lines := []string{fmt.Sprintf("Found %d cosmetics (page %d/%d):", len(matches), page+1, giftPages(matches))}
+	for i := start; i < end; i++ {
+		m := matches[i]
+		lines = append(lines, fmt.Sprintf("%d. %s (%s, %s)", i+1, m.Name, m.DisplayType, m.Rarity))
+	}
+	lines = append(lines, "Reply with a number, next or prev to browse, or cancel.")
+	c.Reply("%s", strings.Join(lines, "\n"))
+}
+
+// pickGift handles one answer to the gift list. Numbers count across pages.
+func pickGift(c Chat, matches []cosmetics.Cosmetic, page *int, body string) PromptResult {
+	answer := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(body), "!"))
+
+	switch answer {
+	case "next", "more", "n":
+		if *page+1 >= giftPages(matches) {
+			c.Reply("That was the last page.")
+			return PromptWait
+		}
+		*page++
+		showGiftPage(c, matches, *page)
+		return PromptWait
+	case "prev", "back", "p":
+		if *page == 0 {
+			c.Reply("That was the first page.")
+			return PromptWait
+		}
+		*page--
+		showGiftPage(c, matches, *page)
+		return PromptWait
+	case "cancel", "stop":
+		c.Reply("Gift cancelled.")
+		return PromptDone
+	}
+
+	n, err := strconv.Atoi(answer)
+	if err != nil {
+		return PromptSkip
+	}
+	if n < 1 || n > len(matches) {
+		c.Reply("Pick a number from 1 to %d.", len(matches))
+		return PromptWait
+	}
+	sendGift(c, matches[n-1])
+	return PromptDone
+}
+
+func sendGift(c Chat, item cosmetics.Cosmetic) {
+	if _, err := profile.Gift(c.AccountID, AccountID, []string{item.TemplateID()}, giftMessage); err != nil {
+		c.Reply("Could not send the gift: %s", err.Error())
+		return
+	}
+
+	// The gift arrives as a GiftBox in common_core, which the client picks
+	// up on its next profile query.
+	c.Reply("Sent you %s (%s)! Open it from your gifts.", item.Name, item.DisplayType)
+}
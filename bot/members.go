@@ -0,0 +1,308 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"neonite-go/account"
+	"neonite-go/party"
+	"neonite-go/routes/xmpp"
+	"neonite-go/structs"
+)
+
+var ErrNoParty = errors.New("not_in_party")
+
+var (
+	membersMu sync.Mutex
+	nextBot   int
+	owned     = make(map[string][]string) // owner -> bot members
+	owners    = make(map[string]string)   // bot member -> owner
+)
+
+// newBotID picks an id for a bot member that no real account has.
+func newBotID() string {
+	membersMu.Lock()
+	defer membersMu.Unlock()
+	for {
+		nextBot++
+		id := AccountID + strconv.Itoa(nextBot)
+		if account.Get(id) == nil {
+			return id
+		}
+	}
+}
+
+func track(ownerId, botId string) {
+	membersMu.Lock()
+	defer membersMu.Unlock()
+	owned[ownerId] = append(owned[ownerId], botId)
+	owners[botId] = ownerId
+}
+
+func forget(botId string) {
+	membersMu.Lock()
+	defer membersMu.Unlock()
+
+	ownerId, ok := owners[botId]
+	if !ok {
+		return
+	}
+	delete(owners, botId)
+	list := owned[ownerId]
+	for i, id := range list {
+		if id == botId {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(owned, ownerId)
+	} else {
+		owned[ownerId] = list
+	}
+}
+
+// Bots returns the bot members an account added.
+func Bots(ownerId string) []string {
+	membersMu.Lock()
+	defer membersMu.Unlock()
+	return append([]string{}, owned[ownerId]...)
+}
+
+func cosmeticPath(kind, id string) string {
+	if id == "" {
+		return "None"
+	}
+	return fmt.Sprintf("/Game/Athena/Items/Cosmetics/%s/%s.%s", kind, id, id)
+}
+
+func mustJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// memberMeta is the member state a bot joins with: a lobby ready member
+// wearing the configured cosmetics, with any configured meta on top.
+func memberMeta(botId string) map[string]string {
+	cfg := structs.Settings.Bot
+	dn := DisplayName()
+
+	meta := map[string]string{
+		"urn:epic:member:dn_s": dn,
+		"urn:epic:member:joinrequestusers_j": mustJSON(map[string]interface{}{
+			"users": []map[string]interface{}{{
+				"id":   botId,
+				"dn":   dn,
+				"plat": "WIN",
+				"data": mustJSON(map[string]string{"CrossplayPreference_i": "1", "SubGame_u": "1"}),
+			}},
+		}),
+		"Default:Location_s":                  "PreLobby",
+		"Default:GameReadiness_s":             "NotReady",
+		"Default:ReadyInputType_s":            "Count",
+		"Default:CurrentInputType_s":          "MouseAndKeyboard",
+		"Default:VoiceChatStatus_s":           "PartyVoice",
+		"Default:SidekickStatus_s":            "None",
+		"Default:FeatDefinition_s":            "None",
+		"Default:PlatformUniqueId_s":          "INVALID",
+		"Default:PlatformSessionId_s":         "",
+		"Default:CrossplayPreference_s":       "OptedIn",
+		"Default:HasPreloadedAthena_b":        "false",
+		"Default:MatchmakingLevel_U":          "0",
+		"Default:HomeBaseVersion_U":           "1",
+		"Default:UtcTimeStartedMatchAthena_s": "0001-01-01T00:00:00.000Z",
+		"Default:FrontendEmote_j": mustJSON(map[string]interface{}{
+			"FrontendEmote": map[string]interface{}{"emoteItemDef": "None", "emoteEKey": "", "emoteSection": -1},
+		}),
+		"Default:AthenaCosmeticLoadout_j": mustJSON(map[string]interface{}{
+			"AthenaCosmeticLoadout": map[string]interface{}{
+				"characterDef":  cosmeticPath("Characters", cfg.Character),
+				"characterEKey": "",
+				"backpackDef":   cosmeticPath("Backpacks", cfg.Backpack),
+				"backpackEKey":  "",
+				"pickaxeDef":    cosmeticPath("PickAxes", cfg.Pickaxe),
+				"pickaxeEKey":   "",
+				"contrailDef":   "None",
+				"contrailEKey":  "",
+				"scratchpad":    []interface{}{},
+			},
+		}),
+		"Default:AthenaCosmeticLoadoutVariants_j": mustJSON(map[string]interface{}{
+			"AthenaCosmeticLoadoutVariants": map[string]interface{}{
+				"vL": map[string]interface{}{
+					"AthenaPickaxe":   map[string]interface{}{"i": []interface{}{}},
+					"AthenaCharacter": map[string]interface{}{"i": []interface{}{}},
+					"AthenaBackpack":  map[string]interface{}{"i": []interface{}{}},
+				},
+			},
+		}),
+		"Default:AthenaBannerInfo_j": mustJSON(map[string]interface{}{
+			"AthenaBannerInfo": map[string]interface{}{
+				"bannerIconId":  "standardbanner2",
+				"bannerColorId": "defaultcolor12",
+				"seasonLevel":   cfg.Level,
+			},
+		}),
+		"Default:BattlePassInfo_j": mustJSON(map[string]interface{}{
+			"BattlePassInfo": map[string]interface{}{
+				"bHasPurchasedPass": false,
+				"passLevel":         cfg.Level,
+				"selfBoostXp":       0,
+				"friendBoostXp":     0,
+			},
+		}),
+		"Default:Platform_j": mustJSON(map[string]interface{}{
+			"Platform": map[string]interface{}{
+				"platformDescription": map[string]interface{}{
+					"name":                "WIN",
+					"platformType":        "DESKTOP",
+					"onlineSubsystem":     "None",
+					"sessionType":         "",
+					"externalAccountType": "",
+					"crossplayPool":       "DESKTOP",
+				},
+			},
+		}),
+	}
+	if strings.HasPrefix(cfg.Character, "CID_") {
+		hero := "HID_" + strings.TrimPrefix(cfg.Character, "CID_")
+		meta["Default:CampaignHero_j"] = mustJSON(map[string]interface{}{
+			"CampaignHero": map[string]interface{}{
+				"heroItemInstanceId": "",
+				"heroType":           fmt.Sprintf("/Game/Athena/Heroes/%s.%s", hero, hero),
+			},
+		})
+	}
+	for k, v := range cfg.Meta {
+		meta[k] = v
+	}
+	return meta
+}
+
+// AddBots adds up to count bot members to the account's current party and
+// returns how many joined. It stops early once the party is full.
+func AddBots(ownerId string, count int) (int, error) {
+	p := party.GetUserParty(ownerId)
+	if p == nil {
+		return 0, ErrNoParty
+	}
+
+	added := 0
+	for ; added < count; added++ {
+		if p = party.Get(p.ID); p == nil {
+			return added, ErrNoParty
+		}
+		if len(p.Members) >= p.Config.MaxSize {
+			break
+		}
+		botId := newBotID()
+		// Tracked before joining, so the join is already seen as a bot's.
+		track(ownerId, botId)
+
+		var join party.JoinInfo
+		join.Connection.ID = fmt.Sprintf("%s@%s/%s", botId, xmpp.Domain, Resource)
+		join.Connection.Meta = map[string]string{
+			"urn:epic:conn:platform_s": "WIN",
+			"urn:epic:conn:type_s":     "game",
+		}
+		join.Meta = memberMeta(botId)
+
+		// Parties that are not open only let invited accounts in, so the
+		// owner invites each bot first.
+		if p.Config.Joinability != party.JoinabilityOpen {
+			if _, err := party.SendInvite(p.ID, ownerId, botId, nil); err != nil {
+				forget(botId)
+				return added, err
+			}
+		}
+		if _, err := party.Join(p.ID, botId, join); err != nil {
+			forget(botId)
+			if p.Config.Joinability != party.JoinabilityOpen {
+				party.CancelInvite(p.ID, botId)
+			}
+			if errors.Is(err, party.ErrPartyFull) {
+				return added, nil
+			}
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// RemoveBots takes every bot the account added out of its party.
+func RemoveBots(ownerId string) int {
+	removed := 0
+	for _, botId := range Bots(ownerId) {
+		if p := party.GetUserParty(botId); p != nil {
+			if _, err := party.Leave(p.ID, botId, botId); err == nil {
+				removed++
+			}
+		}
+		forget(botId)
+	}
+	return removed
+}
+
+// onPartyEvent removes a player's bots once the player leaves the party they
+// were added to, and forgets bots that were kicked.
+func onPartyEvent(e party.Event) {
+	if e.Type != party.EventMemberLeft && e.Type != party.EventMemberKicked {
+		return
+	}
+	if e.Member == nil || e.Party == nil {
+		return
+	}
+
+	if IsBot(e.Member.AccountID) {
+		forget(e.Member.AccountID)
+		return
+	}
+	for _, botId := range Bots(e.Member.AccountID) {
+		if e.Party.Member(botId) != nil {
+			party.Leave(e.Party.ID, botId, botId)
+			forget(botId)
+		}
+	}
+}
+
+func addBotCommand(c Chat, args string) {
+	count := 1
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n < 1 {
+			c.Reply("Usage: addbot [count]")
+			return
+		}
+		count = n
+	}
+	addBots(c, count)
+}
+
+func addBots(c Chat, count int) {
+	added, err := AddBots(c.AccountID, count)
+	switch {
+	case errors.Is(err, ErrNoParty):
+		c.Reply("You are not in a party.")
+	case err != nil:
+		c.Reply("Could not add bots: %s", err.Error())
+	case added == 0:
+		c.Reply("Your party is full.")
+	case added == 1:
+		c.Reply("Added 1 bot.")
+	default:
+		c.Reply("Added %d bots.", added)
+	}
+}
+
+func removeBotsCommand(c Chat, _ string) {
+	removed := RemoveBots(c.AccountID)
+	if removed == 1 {
+		c.Reply("Removed 1 bot.")
+	} else {
+		c.Reply("Removed %d bots.", removed)
+	}
+}
@@ -0,0 +1,143 @@
+package calendar
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"neonite-go/structs"
+)
+
+// bundled is the default calendar, replaced by config/calendar.json when
+// that file exists.
+//
+//go:embed calendar.json
+var bundled []byte
+
+// Event is an event flag the client turns on while it is active. An empty
+// Seasons list means every season, and "{season}" in the type is replaced
+// by the client's season number.
+type Event struct {
+	EventType   string `json:"eventType"`
+	ActiveSince string `json:"activeSince"`
+	ActiveUntil string `json:"activeUntil"`
+	Seasons     []int  `json:"seasons,omitempty"`
+}
+
+// AppliesTo reports whether the event is part of the season.
+func (e Event) AppliesTo(season int) bool {
+	return len(e.Seasons) == 0 || slices.Contains(e.Seasons, season)
+}
+
+// For returns the event as a client in the season sees it.
+func (e Event) For(season int) Event {
+	e.EventType = strings.ReplaceAll(e.EventType, "{season}", strconv.Itoa(season))
+	e.Seasons = nil
+	return e
+}
+
+// State is one entry of the client-events channel. It takes over from the
+// previous state at ValidFrom. State holds extra fields merged into the
+// state block.
+type State struct {
+	ValidFrom string                 `json:"validFrom"`
+	Events    []Event                `json:"events"`
+	State     map[string]interface{} `json:"state"`
+}
+
+// Times are the end dates the client counts down to. Empty fields fall back
+// to the calendar's defaults.
+type Times struct {
+	SeasonBegin        string `json:"seasonBegin"`
+	SeasonEnd          string `json:"seasonEnd"`
+	SeasonDisplayedEnd string `json:"seasonDisplayedEnd"`
+	WeeklyStoreEnd     string `json:"weeklyStoreEnd"`
+	DailyStoreEnd      string `json:"dailyStoreEnd"`
+	StwEventStoreEnd   string `json:"stwEventStoreEnd"`
+	StwWeeklyStoreEnd  string `json:"stwWeeklyStoreEnd"`
+}
+
+func (t Times) over(base Times) Times {
+	pick := func(v, fallback string) string {
+		if v != "" {
+			return v
+		}
+		return fallback
+	}
+	return Times{
+		SeasonBegin:        pick(t.SeasonBegin, base.SeasonBegin),
+		SeasonEnd:          pick(t.SeasonEnd, base.SeasonEnd),
+		SeasonDisplayedEnd: pick(t.SeasonDisplayedEnd, base.SeasonDisplayedEnd),
+		WeeklyStoreEnd:     pick(t.WeeklyStoreEnd, base.WeeklyStoreEnd),
+		DailyStoreEnd:      pick(t.DailyStoreEnd, base.DailyStoreEnd),
+		StwEventStoreEnd:   pick(t.StwEventStoreEnd, base.StwEventStoreEnd),
+		StwWeeklyStoreEnd:  pick(t.StwWeeklyStoreEnd, base.StwWeeklyStoreEnd),
+	}
+}
+
+// Calendar is the offline replacement for Epic's timeline. Seasons holds
+// per season overrides of the default times, keyed by season number.
+type Calendar struct {
+	Times
+	Seasons map[string]Times `json:"seasons"`
+	States  []State          `json:"states"`
+}
+
+var (
+	loadOnce sync.Once
+	current  Calendar
+)
+
+func parse(data []byte) (Calendar, error) {
+	var c Calendar
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Calendar{}, err
+	}
+	sort.SliceStable(c.States, func(i, j int) bool {
+		return c.States[i].ValidFrom < c.States[j].ValidFrom
+	})
+	return c, nil
+}
+
+// Get returns the calendar, reading it on first use.
+func Get() Calendar {
+	loadOnce.Do(func() {
+		c, err := parse(bundled)
+		if err != nil {
+			structs.NeoLog("[Calendar] Bundled calendar is invalid: " + err.Error())
+		}
+
+		path := filepath.Join("config", "calendar.json")
+		if data, err := os.ReadFile(path); err == nil {
+			if custom, err := parse(data); err == nil {
+				c = custom
+			} else {
+				structs.NeoLog("[Calendar] Ignoring " + path + ": " + err.Error())
+			}
+		}
+		current = c
+	})
+	return current
+}
+
+// TimesFor returns the times for a season, with its overrides applied.
+func (c Calendar) TimesFor(season int) Times {
+	return c.Seasons[strconv.Itoa(season)].over(c.Times)
+}
+
+// EventsFor returns a state's events that apply to the season.
+func (s State) EventsFor(season int) []Event {
+	events := []Event{}
+	for _, e := range s.Events {
+		if e.AppliesTo(season) {
+			events = append(events, e.For(season))
+		}
+	}
+	return events
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"neonite-go/keychain"
+	"neonite-go/structs"
+)
+
+const usage = `Usage:
+  neonite-go                                        start the server
+  neonite-go keychain add <guid:key:templateId> [from] [until]
+  neonite-go keychain list`
+
+// runCommand runs a command line tool instead of the server and returns the
+// exit code.
+func runCommand(args []string) int {
+	if len(args) >= 2 && args[0] == "keychain" {
+		switch {
+		case args[1] == "add" && len(args) >= 3 && len(args) <= 5:
+			var from, until string
+			if len(args) > 3 {
+				from = args[3]
+			}
+			if len(args) > 4 {
+				until = args[4]
+			}
+			entry, added, err := keychain.Add(args[2], from, until)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			if added {
+				fmt.Println("Added " + entry.TemplateID)
+			} else {
+				fmt.Println(entry.TemplateID + " is already in the keychain")
+			}
+			return 0
+		case args[1] == "list" && len(args) == 2:
+			for _, key := range keychain.For(structs.BuildInfo{}) {
+				fmt.Println(key)
+			}
+			return 0
+		}
+	}
+	fmt.Fprintln(os.Stderr, usage)
+	return 2
+}
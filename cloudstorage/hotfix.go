@@ -0,0 +1,111 @@
+package cloudstorage
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+
+	"neonite-go/structs"
+)
+
+// bundledTemplates render the hotfixes from the server config. A file of
+// the same name in config/hotfixes/templates replaces one of them, and any
+// other file there is rendered as well.
+//
+//go:embed templates/*.ini
+var bundledTemplates embed.FS
+
+// started stamps the generated files, which only change with the config.
+var started = time.Now()
+
+// HotfixData is what the hotfix templates see.
+type HotfixData struct {
+	structs.HotfixConfig
+	Build structs.BuildInfo
+}
+
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}
+
+func templateDir() string {
+	return filepath.Join(hotfixDir(), "templates")
+}
+
+// hotfixTemplates returns the template sources by file name.
+func hotfixTemplates() map[string][]byte {
+	sources := make(map[string][]byte)
+	bundled, _ := fs.ReadDir(bundledTemplates, "templates")
+	for _, e := range bundled {
+		data, err := bundledTemplates.ReadFile("templates/" + e.Name())
+		if err == nil {
+			sources[e.Name()] = data
+		}
+	}
+
+	list, err := os.ReadDir(templateDir())
+	if err != nil {
+		return sources
+	}
+	for _, e := range list {
+		if !e.Type().IsRegular() || !validName(e.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(templateDir(), e.Name()))
+		if err != nil {
+			structs.NeoLog("[CloudStorage] Could not read template " + e.Name() + ": " + err.Error())
+			continue
+		}
+		sources[e.Name()] = data
+	}
+	return sources
+}
+
+// RenderHotfix renders one hotfix template for a build.
+func RenderHotfix(b structs.BuildInfo, name string) (File, error) {
+	source, ok := hotfixTemplates()[name]
+	if !ok {
+		return File{}, ErrNotFound
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(string(source))
+	if err != nil {
+		return File{}, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, HotfixData{HotfixConfig: structs.Settings.Hotfixes, Build: b}); err != nil {
+		return File{}, err
+	}
+	return File{Name: name, Data: out.Bytes(), Uploaded: started}, nil
+}
+
+// HotfixTemplates lists the names of the hotfix templates.
+func HotfixTemplates() []string {
+	sources := hotfixTemplates()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderHotfixes adds the build's generated hotfixes to files. Templates
+// that fail are logged and left out, and ones that render to nothing are
+// not served.
+func renderHotfixes(b structs.BuildInfo, files map[string]File) {
+	for _, name := range HotfixTemplates() {
+		f, err := RenderHotfix(b, name)
+		if err != nil {
+			structs.NeoLog("[CloudStorage] Could not render " + name + ": " + err.Error())
+			continue
+		}
+		if len(bytes.TrimSpace(f.Data)) > 0 {
+			files[name] = f
+		}
+	}
+}
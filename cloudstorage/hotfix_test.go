@@ -0,0 +1,76 @@
+package cloudstorage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"neonite-go/structs"
+)
+
+func TestRenderHotfix(t *testing.T) {
+	t.Chdir(t.TempDir())
+	old := structs.Settings.Hotfixes
+	t.Cleanup(func() { structs.Settings.Hotfixes = old })
+	structs.Settings.Hotfixes = structs.HotfixConfig{
+		FrontendPlaylist: "Playlist_DefaultSolo",
+		EnabledPlaylists: []string{"Playlist_DefaultDuo"},
+		Features:         map[string]bool{"bEnableGlobalChat": true},
+	}
+
+	dir := filepath.Join("config", "hotfixes", "templates")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	custom := "[Build]\nVersion={{.Build.Version}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "Custom.ini"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Broken.ini"), []byte("{{.Nope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := structs.ParseVersion("7.01")
+	tests := []struct {
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		{"DefaultGame.ini", []string{
+			"!FrontEndPlaylistData=ClearArray",
+			"PlaylistName=Playlist_DefaultSolo, PlaylistAccess=(bEnabled=true, bIsDefaultPlaylist=true",
+			"PlaylistName=Playlist_DefaultDuo, PlaylistAccess=(bEnabled=true, bIsDefaultPlaylist=false",
+			"DisplayPriority=1)",
+		}, false},
+		{"DefaultRuntimeOptions.ini", []string{"[/Script/FortniteGame.FortRuntimeOptions]", "bEnableGlobalChat=true"}, false},
+		{"Custom.ini", []string{"Version=7.01"}, false},
+		{"Broken.ini", nil, true},
+	}
+	for _, tt := range tests {
+		f, err := RenderHotfix(b, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("RenderHotfix(%s) error = %v", tt.name, err)
+			continue
+		}
+		for _, line := range tt.want {
+			if !strings.Contains(string(f.Data), line) {
+				t.Errorf("RenderHotfix(%s) is missing %q:\n%s", tt.name, line, f.Data)
+			}
+		}
+	}
+	if _, err := RenderHotfix(b, "Missing.ini"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RenderHotfix(Missing.ini) error = %v, want %v", err, ErrNotFound)
+	}
+
+	// Templates that render to nothing or fail are not served.
+	structs.Settings.Hotfixes = structs.HotfixConfig{}
+	var names []string
+	for _, f := range SystemFiles(b) {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "Custom.ini" {
+		t.Errorf("SystemFiles = %v, want [Custom.ini]", names)
+	}
+}
@@ -0,0 +1,131 @@
+package cloudstorage
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonite-go/structs"
+)
+
+var ErrNotFound = errors.New("file_not_found")
+
+// File is a cloudstorage file with the metadata the client lists.
+type File struct {
+	Name     string
+	Data     []byte
+	Uploaded time.Time
+}
+
+// Info is a file's entry in a cloudstorage listing.
+type Info struct {
+	UniqueFilename string            `json:"uniqueFilename"`
+	Filename       string            `json:"filename"`
+	Hash           string            `json:"hash"`
+	Hash256        string            `json:"hash256"`
+	Length         int               `json:"length"`
+	ContentType    string            `json:"contentType"`
+	Uploaded       string            `json:"uploaded"`
+	StorageType    string            `json:"storageType"`
+	StorageIds     map[string]string `json:"storageIds"`
+	DoNotCache     bool              `json:"doNotCache"`
+}
+
+// Info returns the file's listing entry.
+func (f File) Info() Info {
+	sha1Sum := sha1.Sum(f.Data)
+	sha256Sum := sha256.Sum256(f.Data)
+	return Info{
+		UniqueFilename: f.Name,
+		Filename:       f.Name,
+		Hash:           hex.EncodeToString(sha1Sum[:]),
+		Hash256:        hex.EncodeToString(sha256Sum[:]),
+		Length:         len(f.Data),
+		ContentType:    "application/octet-stream",
+		Uploaded:       f.Uploaded.UTC().Format("2006-01-02T15:04:05.000Z"),
+		StorageType:    "S3",
+		StorageIds:     map[string]string{},
+		DoNotCache:     true,
+	}
+}
+
+// validName rejects names that could reach outside a storage directory.
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+func hotfixDir() string {
+	return filepath.Join("config", "hotfixes")
+}
+
+// readDir reads the regular files in dir into files, replacing any with
+// the same name. A missing directory is not an error.
+func readDir(dir string, files map[string]File) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			structs.NeoLog("[CloudStorage] Could not read " + dir + ": " + err.Error())
+		}
+		return
+	}
+	for _, e := range list {
+		if !e.Type().IsRegular() || !validName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			structs.NeoLog("[CloudStorage] Could not read " + e.Name() + ": " + err.Error())
+			continue
+		}
+		files[e.Name()] = File{Name: e.Name(), Data: data, Uploaded: info.ModTime()}
+	}
+}
+
+// SystemFiles returns the hotfix files for a build, sorted by name. They
+// start as the files rendered from the hotfix templates. Files in
+// config/hotfixes go to every build and replace generated ones of the same
+// name. A directory named after a season ("14") or a version ("14.60") adds
+// files for those builds only, replacing shared files of the same name; the
+// version directory wins over the season one. Files are read on every call,
+// so edits apply right away.
+func SystemFiles(b structs.BuildInfo) []File {
+	files := make(map[string]File)
+	renderHotfixes(b, files)
+	dir := hotfixDir()
+	readDir(dir, files)
+	if b.Known {
+		readDir(filepath.Join(dir, strconv.Itoa(b.Season)), files)
+		readDir(filepath.Join(dir, b.Version()), files)
+	}
+
+	list := make([]File, 0, len(files))
+	for _, f := range files {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// SystemFile returns one of the build's hotfix files by name.
+func SystemFile(b structs.BuildInfo, name string) (File, error) {
+	if !validName(name) {
+		return File{}, ErrNotFound
+	}
+	for _, f := range SystemFiles(b) {
+		if strings.EqualFold(f.Name, name) {
+			return f, nil
+		}
+	}
+	return File{}, ErrNotFound
+}
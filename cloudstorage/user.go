@@ -0,0 +1,136 @@
+package cloudstorage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"neonite-go/structs"
+)
+
+var (
+	ErrTooLarge    = errors.New("file_too_large")
+	ErrInvalidName = errors.New("invalid_file_name")
+)
+
+// defaultBranch holds the files of clients that did not identify their
+// build.
+const defaultBranch = "default"
+
+// userMu serializes writes so a listing never sees a half written file.
+var userMu sync.RWMutex
+
+// userDir is where an account's files for a build branch live, next to its
+// profiles.
+func userDir(accountId, branch string) (string, error) {
+	if !validName(accountId) {
+		return "", ErrInvalidName
+	}
+	if branch == "" {
+		branch = defaultBranch
+	}
+	if !validName(branch) {
+		return "", ErrInvalidName
+	}
+	return filepath.Join("config", accountId, "cloudstorage", branch), nil
+}
+
+// UserFiles returns an account's files for a build branch, sorted by name.
+func UserFiles(accountId, branch string) ([]File, error) {
+	dir, err := userDir(accountId, branch)
+	if err != nil {
+		return nil, err
+	}
+	userMu.RLock()
+	defer userMu.RUnlock()
+
+	files := make(map[string]File)
+	readDir(dir, files)
+	list := make([]File, 0, len(files))
+	for _, f := range files {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// UserFile returns one of an account's files.
+func UserFile(accountId, branch, name string) (File, error) {
+	dir, err := userDir(accountId, branch)
+	if err != nil {
+		return File{}, err
+	}
+	if !validName(name) {
+		return File{}, ErrInvalidName
+	}
+	userMu.RLock()
+	defer userMu.RUnlock()
+
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && !info.Mode().IsRegular()) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Name: name, Data: data, Uploaded: info.ModTime()}, nil
+}
+
+// PutUserFile saves one of an account's files, replacing any earlier
+// version. Files over the configured size limit are refused.
+func PutUserFile(accountId, branch, name string, data []byte) (File, error) {
+	dir, err := userDir(accountId, branch)
+	if err != nil {
+		return File{}, err
+	}
+	if !validName(name) {
+		return File{}, ErrInvalidName
+	}
+	if int64(len(data)) > structs.Settings.CloudStorageMaxSize {
+		return File{}, ErrTooLarge
+	}
+	userMu.Lock()
+	defer userMu.Unlock()
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return File{}, err
+	}
+	// Write next to the file and rename, so a failed save keeps the old one.
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return File{}, err
+	}
+	return File{Name: name, Data: data, Uploaded: time.Now()}, nil
+}
+
+// DeleteUserFile removes one of an account's files.
+func DeleteUserFile(accountId, branch, name string) error {
+	dir, err := userDir(accountId, branch)
+	if err != nil {
+		return err
+	}
+	if !validName(name) {
+		return ErrInvalidName
+	}
+	userMu.Lock()
+	defer userMu.Unlock()
+
+	err = os.Remove(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
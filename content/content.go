@@ -0,0 +1,238 @@
+package content
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"neonite-go/structs"
+)
+
+// bundled is the default content, replaced by config/content.json when that
+// file exists.
+//
+//go:embed content.json
+var bundled []byte
+
+// Localized is a text in several languages, keyed by language tag ("en",
+// "pt-BR"). A plain JSON string is read as English.
+type Localized map[string]string
+
+func (l *Localized) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = Localized{"en": s}
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*l = m
+	return nil
+}
+
+// In returns the text in the first of langs it has, trying each tag and
+// then its base language, then English, then any language.
+func (l Localized) In(langs []string) string {
+	for _, lang := range langs {
+		if s, ok := l[lang]; ok {
+			return s
+		}
+		if base, _, ok := strings.Cut(lang, "-"); ok {
+			if s, ok := l[base]; ok {
+				return s
+			}
+		}
+	}
+	if s, ok := l["en"]; ok {
+		return s
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return ""
+	}
+	return l[keys[0]]
+}
+
+// Languages reads an Accept-Language header into language tags, most
+// preferred first.
+func Languages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var list []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		list = append(list, weighted{tag, q})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+
+	tags := make([]string, len(list))
+	for i, w := range list {
+		tags[i] = w.tag
+	}
+	return tags
+}
+
+// Builds limits an entry to a range of builds such as "4.40" to "9.41".
+// Empty ends are unbounded, and builds that did not identify themselves see
+// every entry.
+type Builds struct {
+	From  string `json:"from,omitempty"`
+	Until string `json:"until,omitempty"`
+}
+
+// AppliesTo reports whether the build is in the range.
+func (r Builds) AppliesTo(b structs.BuildInfo) bool {
+	if !b.Known {
+		return true
+	}
+	if from, ok := structs.ParseVersion(r.From); ok && !b.AtLeast(from.Major, from.Minor) {
+		return false
+	}
+	if until, ok := structs.ParseVersion(r.Until); ok && b.AtLeast(until.Major, until.Minor+1) {
+		return false
+	}
+	return true
+}
+
+func (r Builds) valid() bool {
+	for _, v := range []string{r.From, r.Until} {
+		if _, ok := structs.ParseVersion(v); v != "" && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Notice is an emergency notice shown over the lobby.
+type Notice struct {
+	Builds
+	Title  Localized `json:"title"`
+	Body   Localized `json:"body"`
+	Hidden bool      `json:"hidden"`
+}
+
+// Playlist describes a playlist's tile in the mode select screen.
+type Playlist struct {
+	Builds
+	Name        string    `json:"name"`
+	Image       string    `json:"image"`
+	DisplayName Localized `json:"displayName"`
+	Description Localized `json:"description"`
+	Violator    Localized `json:"violator"`
+}
+
+// Tournament is a tournament's poster and details. Extra holds further
+// fields, such as the poster colors, passed to the client as they are.
+type Tournament struct {
+	Builds
+	DisplayID          string                 `json:"displayId"`
+	TitleLine1         Localized              `json:"titleLine1"`
+	TitleLine2         Localized              `json:"titleLine2"`
+	ScheduleInfo       Localized              `json:"scheduleInfo"`
+	FlavorDescription  Localized              `json:"flavorDescription"`
+	DetailsDescription Localized              `json:"detailsDescription"`
+	ShortFormatTitle   Localized              `json:"shortFormatTitle"`
+	LongFormatTitle    Localized              `json:"longFormatTitle"`
+	PosterFrontImage   string                 `json:"posterFrontImage"`
+	PosterBackImage    string                 `json:"posterBackImage"`
+	LoadingScreenImage string                 `json:"loadingScreenImage"`
+	PlaylistTileImage  string                 `json:"playlistTileImage"`
+	Extra              map[string]interface{} `json:"extra"`
+}
+
+// Subgame is a mode's card in the subgame select screen.
+type Subgame struct {
+	Title Localized `json:"title"`
+	Body  Localized `json:"body"`
+	Image string    `json:"image"`
+}
+
+// Pages is the content behind /content/api/pages/fortnite-game. Subgames
+// is keyed by the client's names: battleRoyale, creative, saveTheWorld and
+// saveTheWorldUnowned.
+type Pages struct {
+	EmergencyNotices []Notice           `json:"emergencyNotices"`
+	News             []News             `json:"news"`
+	Playlists        []Playlist         `json:"playlists"`
+	Tournaments      []Tournament       `json:"tournaments"`
+	Subgames         map[string]Subgame `json:"subgames"`
+}
+
+var (
+	loadOnce sync.Once
+	current  Pages
+)
+
+func parse(data []byte) (Pages, error) {
+	var p Pages
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Pages{}, err
+	}
+	return p, nil
+}
+
+// warnRanges logs entries whose build range cannot be read; they are shown
+// to every build.
+func warnRanges(p Pages) {
+	check := func(kind, name string, r Builds) {
+		if !r.valid() {
+			structs.NeoLog("[Content] " + kind + " " + name + " has an invalid build range")
+		}
+	}
+	for i, n := range p.EmergencyNotices {
+		check("Emergency notice", strconv.Itoa(i), n.Builds)
+	}
+	for _, n := range p.News {
+		check("News", n.ID, n.Builds)
+	}
+	for _, pl := range p.Playlists {
+		check("Playlist", pl.Name, pl.Builds)
+	}
+	for _, t := range p.Tournaments {
+		check("Tournament", t.DisplayID, t.Builds)
+	}
+}
+
+// Get returns the content pages, reading them on first use.
+func Get() Pages {
+	loadOnce.Do(func() {
+		p, err := parse(bundled)
+		if err != nil {
+			structs.NeoLog("[Content] Bundled content is invalid: " + err.Error())
+		}
+
+		path := filepath.Join("config", "content.json")
+		if data, err := os.ReadFile(path); err == nil {
+			if custom, err := parse(data); err == nil {
+				p = custom
+			} else {
+				structs.NeoLog("[Content] Ignoring " + path + ": " + err.Error())
+			}
+		}
+		warnRanges(p)
+		current = p
+	})
+	return current
+}
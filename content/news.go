@@ -0,0 +1,152 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"neonite-go/structs"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNewsNotFound = errors.New("news_not_found")
+	ErrInvalidNews  = errors.New("invalid_news")
+)
+
+// News is a message of the day in the lobby. Entries past Expires are no
+// longer shown.
+type News struct {
+	Builds
+	ID              string    `json:"id"`
+	Title           Localized `json:"title"`
+	Body            Localized `json:"body"`
+	Image           string    `json:"image"`
+	TileImage       string    `json:"tileImage"`
+	SortingPriority int       `json:"sortingPriority"`
+	Hidden          bool      `json:"hidden"`
+	Posted          time.Time `json:"posted,omitzero"`
+	Expires         time.Time `json:"expires,omitzero"`
+}
+
+// Active reports whether the entry is shown at t.
+func (n News) Active(t time.Time) bool {
+	return !n.Hidden && (n.Expires.IsZero() || t.Before(n.Expires))
+}
+
+var (
+	newsMu sync.Mutex
+	posted []News
+	loaded bool
+)
+
+func newsPath() string {
+	return filepath.Join("config", "news.json")
+}
+
+// loadNews reads the posted news the first time it is needed. Callers must
+// hold newsMu.
+func loadNews() {
+	if loaded {
+		return
+	}
+	loaded = true
+	data, err := os.ReadFile(newsPath())
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &posted); err != nil {
+		structs.NeoLog("[Content] Ignoring " + newsPath() + ": " + err.Error())
+		posted = nil
+	}
+}
+
+func saveNews() error {
+	bytes, err := json.MarshalIndent(posted, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(newsPath()), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(newsPath(), bytes, 0644)
+}
+
+// Posted returns the news posted through the admin endpoint, expired
+// entries included, newest first.
+func Posted() []News {
+	newsMu.Lock()
+	defer newsMu.Unlock()
+	loadNews()
+	list := append([]News{}, posted...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Posted.After(list[j].Posted) })
+	return list
+}
+
+// PostNews adds a news entry and saves it. A new id is assigned when the
+// entry has none; posting an id again replaces that entry, keeping its
+// expiry unless n sets one or it has already passed.
+func PostNews(n News) (News, error) {
+	if len(n.Title) == 0 && len(n.Body) == 0 {
+		return News{}, ErrInvalidNews
+	}
+	if !n.Builds.valid() {
+		return News{}, ErrInvalidNews
+	}
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	n.Posted = time.Now().UTC()
+
+	newsMu.Lock()
+	defer newsMu.Unlock()
+	loadNews()
+	replaced := false
+	for i := range posted {
+		if posted[i].ID == n.ID {
+			if n.Expires.IsZero() && n.Posted.Before(posted[i].Expires) {
+				n.Expires = posted[i].Expires
+			}
+			posted[i] = n
+			replaced = true
+		}
+	}
+	if !replaced {
+		posted = append(posted, n)
+	}
+	return n, saveNews()
+}
+
+// ExpireNews takes a posted entry down now. The entry is kept so it can be
+// posted again.
+func ExpireNews(id string) (News, error) {
+	newsMu.Lock()
+	defer newsMu.Unlock()
+	loadNews()
+	for i := range posted {
+		if posted[i].ID == id {
+			posted[i].Expires = time.Now().UTC()
+			return posted[i], saveNews()
+		}
+	}
+	return News{}, ErrNewsNotFound
+}
+
+// ActiveNews returns the news a build should see now: the posted entries
+// and those in the content file, highest priority first and then newest.
+func ActiveNews(b structs.BuildInfo) []News {
+	now := time.Now()
+	var list []News
+	for _, n := range append(Posted(), Get().News...) {
+		if n.Active(now) && n.AppliesTo(b) {
+			list = append(list, n)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].SortingPriority > list[j].SortingPriority })
+	return list
+}
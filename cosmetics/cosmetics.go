@@ -0,0 +1,272 @@
+package cosmetics
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"neonite-go/structs"
+)
+
+// bundled is a small built in catalog. A full list, in this format or as
+// the response of fortnite-api.com's /v2/cosmetics/br, can be dropped in
+// config/cosmetics.json instead.
+//
+//go:embed cosmetics.json
+var bundled []byte
+
+type Cosmetic struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	DisplayType string `json:"displayType"`
+	Rarity      string `json:"rarity"`
+	Set         string `json:"set"`
+}
+
+// TemplateID is the item's id in the athena profile, e.g.
+// "AthenaCharacter:CID_286_Athena_Commando_F_NeonCat".
+func (c Cosmetic) TemplateID() string {
+	return c.Type + ":" + c.ID
+}
+
+// NameIs reports whether the cosmetic is called name, ignoring case and
+// punctuation.
+func (c Cosmetic) NameIs(name string) bool {
+	return normalize(c.Name) == normalize(name)
+}
+
+// apiCosmetic is one entry of a fortnite-api.com dump.
+type apiCosmetic struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        struct {
+		DisplayValue string `json:"displayValue"`
+		BackendValue string `json:"backendValue"`
+	} `json:"type"`
+	Rarity struct {
+		Value string `json:"value"`
+	} `json:"rarity"`
+	Set *struct {
+		Value string `json:"value"`
+	} `json:"set"`
+}
+
+var (
+	loadOnce sync.Once
+	all      []Cosmetic
+	byID     map[string]int
+)
+
+func parse(data []byte) ([]Cosmetic, error) {
+	var list []Cosmetic
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+
+	var dump struct {
+		Data []apiCosmetic `json:"data"`
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, err
+	}
+	if dump.Data == nil {
+		return nil, errors.New("no cosmetics in file")
+	}
+	for _, a := range dump.Data {
+		c := Cosmetic{
+			ID:          a.ID,
+			Name:        a.Name,
+			Description: a.Description,
+			Type:        a.Type.BackendValue,
+			DisplayType: a.Type.DisplayValue,
+			Rarity:      a.Rarity.Value,
+		}
+		if a.Set != nil {
+			c.Set = a.Set.Value
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+func load() {
+	loadOnce.Do(func() {
+		list, err := parse(bundled)
+		if err != nil {
+			structs.NeoLog("[Cosmetics] Bundled catalog is invalid: " + err.Error())
+		}
+
+		path := filepath.Join("config", "cosmetics.json")
+		if data, err := os.ReadFile(path); err == nil {
+			if custom, err := parse(data); err == nil {
+				list = custom
+			} else {
+				structs.NeoLog("[Cosmetics] Ignoring " + path + ": " + err.Error())
+			}
+		}
+
+		byID = make(map[string]int, len(list))
+		for _, c := range list {
+			if c.ID == "" || c.Type == "" {
+				continue
+			}
+			if _, dup := byID[strings.ToLower(c.ID)]; dup {
+				continue
+			}
+			byID[strings.ToLower(c.ID)] = len(all)
+			all = append(all, c)
+		}
+		structs.NeoLog("[Cosmetics] Loaded " + strconv.Itoa(len(all)) + " cosmetics")
+	})
+}
+
+// All returns every cosmetic in the catalog.
+func All() []Cosmetic {
+	load()
+	return append([]Cosmetic{}, all...)
+}
+
+// Get finds a cosmetic by id or template id, ignoring case.
+func Get(id string) (Cosmetic, bool) {
+	load()
+	if _, bare, ok := strings.Cut(id, ":"); ok {
+		id = bare
+	}
+	i, ok := byID[strings.ToLower(id)]
+	if !ok {
+		return Cosmetic{}, false
+	}
+	return all[i], true
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			space = false
+		case !space && b.Len() > 0:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func isSubsequence(q, s string) bool {
+	rq := []rune(q)
+	i := 0
+	for _, r := range s {
+		if i < len(rq) && rq[i] == r {
+			i++
+		}
+	}
+	return i == len(rq)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// score rates how well a cosmetic matches a normalized query, 0 meaning no
+// match. Names beat ids, and exact or prefix matches beat looser ones.
+func score(q string, c Cosmetic) int {
+	name := normalize(c.Name)
+	id := strings.ToLower(c.ID)
+	compact := strings.ReplaceAll(q, " ", "")
+
+	switch {
+	case name == q:
+		return 100
+	case id == compact || strings.ToLower(c.TemplateID()) == compact:
+		return 95
+	case strings.HasPrefix(name, q):
+		return 80
+	}
+	for _, word := range strings.Fields(name) {
+		if strings.HasPrefix(word, q) {
+			return 70
+		}
+	}
+	switch {
+	case strings.Contains(name, q):
+		return 60
+	case len(compact) >= 3 && strings.Contains(id, compact):
+		return 40
+	}
+	if typos := len(q) / 4; typos > 0 {
+		if d := levenshtein(name, q); d <= typos {
+			return 35 - d
+		}
+	}
+	if len(compact) >= 3 && isSubsequence(compact, strings.ReplaceAll(name, " ", "")) {
+		return 20
+	}
+	return 0
+}
+
+// Search returns the cosmetics matching query by name or id, best first.
+// Small typos and skipped letters still match, ranked below exact hits.
+func Search(query string) []Cosmetic {
+	load()
+	q := normalize(query)
+	if q == "" {
+		return nil
+	}
+
+	type match struct {
+		c     Cosmetic
+		score int
+	}
+	var matches []match
+	for _, c := range all {
+		if s := score(q, c); s > 0 {
+			matches = append(matches, match{c, s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.c.Name) != len(b.c.Name) {
+			return len(a.c.Name) < len(b.c.Name)
+		}
+		return a.c.Name < b.c.Name
+	})
+
+	result := make([]Cosmetic, len(matches))
+	for i, m := range matches {
+		result[i] = m.c
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package cosmetics
+
+import "testing"
+
+func TestIsSubsequence(t *testing.T) {
+	for _, tt := range []struct {
+		q, s string
+		want bool
+	}{
+		{"rnx", "renegaderaider", false},
+		{"rgr", "renegaderaider", true},
+		{"", "anything", true},
+		{"abc", "ab", false},
+		{"éé", "éclairé", true},
+		{"éa", "éclair", true},
+		{"ae", "éclair", false},
+		{"ヒーロー", "ヒーローズ", true},
+	} {
+		if got := isSubsequence(tt.q, tt.s); got != tt.want {
+			t.Errorf("isSubsequence(%q, %q) = %v, want %v", tt.q, tt.s, got, tt.want)
+		}
+	}
+}
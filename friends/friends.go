@@ -0,0 +1,321 @@
+package friends
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusAccepted = "ACCEPTED"
+	StatusPending  = "PENDING"
+
+	DirectionInbound  = "INBOUND"
+	DirectionOutbound = "OUTBOUND"
+)
+
+var (
+	ErrSelfFriend       = errors.New("self_friend")
+	ErrAlreadyFriends   = errors.New("duplicate_friendship")
+	ErrAlreadyRequested = errors.New("friend_request_already_sent")
+	ErrNotFound         = errors.New("friendship_not_found")
+	ErrBlocked          = errors.New("cannot_friend_due_to_target_settings")
+	ErrNotBlocked       = errors.New("not_blocked")
+	ErrInvalidAccount   = errors.New("invalid_account_id")
+)
+
+type Friend struct {
+	AccountID string `json:"accountId"`
+	Status    string `json:"status"`
+	Direction string `json:"direction"`
+	Alias     string `json:"alias"`
+	Note      string `json:"note"`
+	Favorite  bool   `json:"favorite"`
+	Created   string `json:"created"`
+}
+
+type Blocked struct {
+	AccountID string `json:"accountId"`
+	Created   string `json:"created"`
+}
+
+type List struct {
+	Friends []Friend  `json:"friends"`
+	Blocked []Blocked `json:"blocklist"`
+}
+
+// Action describes what a call to Add did, so callers can notify both sides.
+type Action int
+
+const (
+	Requested Action = iota
+	Accepted
+)
+
+var (
+	mu    sync.Mutex
+	lists = make(map[string]*List)
+)
+
+func listPath(accountId string) string {
+	return filepath.Join("config", accountId, "friends.json")
+}
+
+// validIDs reports whether every id is safe to use as a directory name under
+// config.
+func validIDs(ids ...string) bool {
+	for _, id := range ids {
+		if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the cached list for an account, reading it from disk on first
+// use. Callers must hold mu.
+func get(accountId string) *List {
+	if l, ok := lists[accountId]; ok {
+		return l
+	}
+	l := &List{}
+	if !validIDs(accountId) {
+		return l
+	}
+	if data, err := os.ReadFile(listPath(accountId)); err == nil {
+		json.Unmarshal(data, l)
+	}
+	lists[accountId] = l
+	return l
+}
+
+func save(accountId string) error {
+	if !validIDs(accountId) {
+		return ErrInvalidAccount
+	}
+	bytes, err := json.MarshalIndent(get(accountId), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(listPath(accountId)), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(listPath(accountId), bytes, 0644)
+}
+
+func saveBoth(a, b string) error {
+	if err := save(a); err != nil {
+		return err
+	}
+	return save(b)
+}
+
+func (l *List) find(accountId string) int {
+	for i, f := range l.Friends {
+		if f.AccountID == accountId {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *List) remove(accountId string) bool {
+	i := l.find(accountId)
+	if i < 0 {
+		return false
+	}
+	l.Friends = append(l.Friends[:i], l.Friends[i+1:]...)
+	return true
+}
+
+func (l *List) isBlocked(accountId string) bool {
+	for _, b := range l.Blocked {
+		if b.AccountID == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a copy of the account's friend list.
+func Get(accountId string) List {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l := get(accountId)
+	return List{
+		Friends: append([]Friend{}, l.Friends...),
+		Blocked: append([]Blocked{}, l.Blocked...),
+	}
+}
+
+// Filter returns the friend entries with the given status and, if set,
+// direction.
+func (l List) Filter(status, direction string) []Friend {
+	out := []Friend{}
+	for _, f := range l.Friends {
+		if f.Status == status && (direction == "" || f.Direction == direction) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func IsFriend(accountId, friendId string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l := get(accountId)
+	i := l.find(friendId)
+	return i >= 0 && l.Friends[i].Status == StatusAccepted
+}
+
+// Add sends a friend request from accountId to friendId, or accepts the
+// pending request friendId already sent.
+func Add(accountId, friendId string) (Action, error) {
+	if !validIDs(accountId, friendId) {
+		return Requested, ErrInvalidAccount
+	}
+	if accountId == friendId {
+		return Requested, ErrSelfFriend
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	from, to := get(accountId), get(friendId)
+	if from.isBlocked(friendId) || to.isBlocked(accountId) {
+		return Requested, ErrBlocked
+	}
+
+	if i := from.find(friendId); i >= 0 {
+		f := &from.Friends[i]
+		switch {
+		case f.Status == StatusAccepted:
+			return Requested, ErrAlreadyFriends
+		case f.Direction == DirectionOutbound:
+			return Requested, ErrAlreadyRequested
+		}
+
+		f.Status = StatusAccepted
+		if j := to.find(accountId); j >= 0 {
+			to.Friends[j].Status = StatusAccepted
+		}
+		return Accepted, saveBoth(accountId, friendId)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	from.Friends = append(from.Friends, Friend{
+		AccountID: friendId,
+		Status:    StatusPending,
+		Direction: DirectionOutbound,
+		Created:   now,
+	})
+	to.remove(accountId)
+	to.Friends = append(to.Friends, Friend{
+		AccountID: accountId,
+		Status:    StatusPending,
+		Direction: DirectionInbound,
+		Created:   now,
+	})
+	return Requested, saveBoth(accountId, friendId)
+}
+
+// Remove deletes a friendship or pending request in either direction, which
+// covers removing, declining and cancelling. It returns the removed entry as
+// seen by accountId.
+func Remove(accountId, friendId string) (Friend, error) {
+	if !validIDs(accountId, friendId) {
+		return Friend{}, ErrInvalidAccount
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	from, to := get(accountId), get(friendId)
+	i := from.find(friendId)
+	if i < 0 {
+		return Friend{}, ErrNotFound
+	}
+	removed := from.Friends[i]
+	from.remove(friendId)
+	to.remove(accountId)
+	return removed, saveBoth(accountId, friendId)
+}
+
+// RemovalReason names why a friendship went away from the point of view of
+// the account that removed it.
+func RemovalReason(removed Friend) string {
+	switch {
+	case removed.Status == StatusAccepted:
+		return "DELETED"
+	case removed.Direction == DirectionOutbound:
+		return "ABORTED"
+	default:
+		return "REJECTED"
+	}
+}
+
+// Block drops any friendship between the two accounts and adds friendId to
+// the account's block list. It reports whether a friendship was dropped.
+func Block(accountId, friendId string) (bool, error) {
+	if !validIDs(accountId, friendId) {
+		return false, ErrInvalidAccount
+	}
+	if accountId == friendId {
+		return false, ErrSelfFriend
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	from, to := get(accountId), get(friendId)
+	removed := from.remove(friendId)
+	to.remove(accountId)
+	if !from.isBlocked(friendId) {
+		from.Blocked = append(from.Blocked, Blocked{
+			AccountID: friendId,
+			Created:   time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+	return removed, saveBoth(accountId, friendId)
+}
+
+func Unblock(accountId, friendId string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l := get(accountId)
+	for i, b := range l.Blocked {
+		if b.AccountID == friendId {
+			l.Blocked = append(l.Blocked[:i], l.Blocked[i+1:]...)
+			return save(accountId)
+		}
+	}
+	return ErrNotBlocked
+}
+
+func SetAlias(accountId, friendId, alias string) error {
+	return update(accountId, friendId, func(f *Friend) { f.Alias = alias })
+}
+
+func SetNote(accountId, friendId, note string) error {
+	return update(accountId, friendId, func(f *Friend) { f.Note = note })
+}
+
+func update(accountId, friendId string, fn func(f *Friend)) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l := get(accountId)
+	i := l.find(friendId)
+	if i < 0 || l.Friends[i].Status != StatusAccepted {
+		return ErrNotFound
+	}
+	fn(&l.Friends[i])
+	return save(accountId)
+}
@@ -0,0 +1,14 @@
+package friends
+
+import "testing"
+
+func TestRejectsPathAccountIDs(t *testing.T) {
+	for _, id := range []string{"", ".", "..", "../x", `..\x`} {
+		if _, err := Add("alice", id); err != ErrInvalidAccount {
+			t.Errorf("Add(%q) = %v, want ErrInvalidAccount", id, err)
+		}
+		if _, err := Remove(id, "alice"); err != ErrInvalidAccount {
+			t.Errorf("Remove(%q) = %v, want ErrInvalidAccount", id, err)
+		}
+	}
+}
@@ -3,7 +3,8 @@ module neonite-go
 go 1.24.3
 
 require (
-	github.com/go-chi/chi/v5 v5.2.1 
-	github.com/google/uuid v1.6.0 
-	github.com/gorilla/mux v1.8.1 
+	github.com/go-chi/chi/v5 v5.2.1
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
 )
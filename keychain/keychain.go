@@ -0,0 +1,275 @@
+package keychain
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"neonite-go/structs"
+)
+
+var (
+	ErrInvalidEntry   = errors.New("invalid_keychain_entry")
+	ErrInvalidVersion = errors.New("invalid_version")
+	ErrConflict       = errors.New("keychain_guid_conflict")
+)
+
+// Entry is one AES key the client needs to decrypt a pak file, sent as
+// "guid:base64key:templateId". From and Until limit it to a range of builds
+// such as "14.40"; empty means unbounded.
+type Entry struct {
+	GUID       string
+	Key        string
+	TemplateID string
+	From       string
+	Until      string
+}
+
+// String returns the entry as the client expects it.
+func (e Entry) String() string {
+	return e.GUID + ":" + e.Key + ":" + e.TemplateID
+}
+
+// Parse reads and validates a "guid:base64key:templateId" entry. The guid
+// is 32 hex digits and the key a base64 encoded 256 bit AES key.
+func Parse(s string) (Entry, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 3)
+	if len(parts) != 3 {
+		return Entry{}, fmt.Errorf("%w: expected guid:key:templateId", ErrInvalidEntry)
+	}
+	e := Entry{GUID: strings.ToUpper(parts[0]), Key: parts[1], TemplateID: parts[2]}
+
+	if guid, err := hex.DecodeString(e.GUID); err != nil || len(guid) != 16 {
+		return Entry{}, fmt.Errorf("%w: guid %q is not 32 hex digits", ErrInvalidEntry, parts[0])
+	}
+	if key, err := base64.StdEncoding.DecodeString(e.Key); err != nil || len(key) != 32 {
+		return Entry{}, fmt.Errorf("%w: key is not a base64 256 bit key", ErrInvalidEntry)
+	}
+	if typ, id, ok := strings.Cut(e.TemplateID, ":"); !ok || typ == "" || id == "" {
+		return Entry{}, fmt.Errorf("%w: template id %q is not type:id", ErrInvalidEntry, e.TemplateID)
+	}
+	return e, nil
+}
+
+func parseVersion(v string) (int, int, error) {
+	majorStr, minorStr, _ := strings.Cut(v, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+	}
+	minor := 0
+	if minorStr != "" {
+		if minor, err = strconv.Atoi(minorStr); err != nil {
+			return 0, 0, fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+		}
+	}
+	return major, minor, nil
+}
+
+func (e Entry) validateRange() error {
+	for _, v := range []string{e.From, e.Until} {
+		if v == "" {
+			continue
+		}
+		if _, _, err := parseVersion(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppliesTo reports whether a build should get the key. Builds that did not
+// identify themselves get every key.
+func (e Entry) AppliesTo(b structs.BuildInfo) bool {
+	if !b.Known {
+		return true
+	}
+	if e.From != "" {
+		major, minor, _ := parseVersion(e.From)
+		if !b.AtLeast(major, minor) {
+			return false
+		}
+	}
+	if e.Until != "" {
+		major, minor, _ := parseVersion(e.Until)
+		if b.Major > major || (b.Major == major && b.Minor > minor) {
+			return false
+		}
+	}
+	return true
+}
+
+// fileEntry is an entry in the keychain file: the bare string, or an object
+// when it is limited to some builds.
+type fileEntry struct {
+	Key   string `json:"key"`
+	From  string `json:"from,omitempty"`
+	Until string `json:"until,omitempty"`
+}
+
+func (f *fileEntry) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &f.Key); err == nil {
+		return nil
+	}
+	type plain fileEntry
+	return json.Unmarshal(data, (*plain)(f))
+}
+
+func (f fileEntry) MarshalJSON() ([]byte, error) {
+	if f.From == "" && f.Until == "" {
+		return json.Marshal(f.Key)
+	}
+	type plain fileEntry
+	return json.Marshal(plain(f))
+}
+
+var (
+	mu      sync.RWMutex
+	entries []Entry
+	loaded  bool
+)
+
+func storePath() string {
+	return filepath.Join("config", "keychain.json")
+}
+
+// legacyPath is where the keychain used to be read from.
+const legacyPath = "keychain.json"
+
+// add appends an entry unless its guid is already known. Callers must hold
+// mu for writing.
+func add(e Entry) (bool, error) {
+	for _, existing := range entries {
+		if existing.GUID != e.GUID {
+			continue
+		}
+		if existing.Key != e.Key {
+			return false, fmt.Errorf("%w: %s", ErrConflict, e.GUID)
+		}
+		return false, nil
+	}
+	entries = append(entries, e)
+	return true, nil
+}
+
+// load reads the keychain the first time it is needed, logging and skipping
+// entries that are malformed or repeat a guid. Callers must hold mu for
+// writing.
+func load() {
+	if loaded {
+		return
+	}
+	loaded = true
+
+	path := storePath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		path = legacyPath
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		if !os.IsNotExist(err) {
+			structs.NeoLog("[Keychain] Could not read " + path + ": " + err.Error())
+		}
+		return
+	}
+
+	var list []fileEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		structs.NeoLog("[Keychain] Ignoring " + path + ": " + err.Error())
+		return
+	}
+	skipped := 0
+	for i, f := range list {
+		e, err := Parse(f.Key)
+		if err == nil {
+			e.From, e.Until = f.From, f.Until
+			err = e.validateRange()
+		}
+		if err == nil {
+			_, err = add(e)
+		}
+		if err != nil {
+			structs.NeoLog(fmt.Sprintf("[Keychain] Skipping entry %d of %s: %s", i, path, err.Error()))
+			skipped++
+		}
+	}
+	structs.NeoLog(fmt.Sprintf("[Keychain] Loaded %d keys (%d skipped)", len(entries), skipped))
+}
+
+func save() error {
+	list := make([]fileEntry, len(entries))
+	for i, e := range entries {
+		list[i] = fileEntry{Key: e.String(), From: e.From, Until: e.Until}
+	}
+	bytes, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(storePath()), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(storePath(), bytes, 0644)
+}
+
+// Load reads and validates the keychain so problems show up at startup
+// instead of on the first request.
+func Load() {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+}
+
+// Add validates an entry and saves it. It reports false without an error
+// when the key is already in the keychain.
+func Add(key, from, until string) (Entry, bool, error) {
+	e, err := Parse(key)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	e.From, e.Until = from, until
+	if err := e.validateRange(); err != nil {
+		return Entry{}, false, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	added, err := add(e)
+	if err != nil || !added {
+		return e, false, err
+	}
+	if err := save(); err != nil {
+		entries = entries[:len(entries)-1]
+		return Entry{}, false, err
+	}
+	return e, true, nil
+}
+
+// For returns the keys a build should get, sorted by template id.
+func For(b structs.BuildInfo) []string {
+	mu.Lock()
+	load()
+	list := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.AppliesTo(b) {
+			list = append(list, e)
+		}
+	}
+	mu.Unlock()
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].TemplateID < list[j].TemplateID })
+	keys := make([]string, len(list))
+	for i, e := range list {
+		keys[i] = e.String()
+	}
+	return keys
+}
@@ -0,0 +1,245 @@
+package locker
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"neonite-go/cosmetics"
+	"neonite-go/party"
+	"neonite-go/profile"
+	"neonite-go/structs"
+)
+
+const sandboxLoadout = "sandbox_loadout"
+
+var (
+	ErrNoLoadout = errors.New("loadout_not_found")
+
+	// errUnchanged aborts a profile update that would not equip anything.
+	errUnchanged = errors.New("unchanged")
+)
+
+// Loadout maps locker slot names ("Character", "Dance", ...) to the template
+// ids equipped in them. Empty strings are empty slots.
+type Loadout map[string][]string
+
+// slotTypes gives the athena item type each locker slot holds.
+var slotTypes = map[string]string{
+	"Character":       "AthenaCharacter",
+	"Backpack":        "AthenaBackpack",
+	"Pickaxe":         "AthenaPickaxe",
+	"Glider":          "AthenaGlider",
+	"SkyDiveContrail": "AthenaSkyDiveContrail",
+	"Dance":           "AthenaDance",
+	"ItemWrap":        "AthenaItemWrap",
+	"LoadingScreen":   "AthenaLoadingScreen",
+	"MusicPack":       "AthenaMusicPack",
+}
+
+// templateFromDef turns a party meta cosmetic reference into a template id.
+// References are either asset paths such as
+// "/Game/Athena/Items/Cosmetics/Characters/CID_001.CID_001" or primary
+// asset ids such as "AthenaCharacter:cid_001".
+func templateFromDef(slot, def string) string {
+	if def == "" || def == "None" {
+		return ""
+	}
+	if strings.Contains(def, ":") && !strings.HasPrefix(def, "/") {
+		return def
+	}
+	name := path.Base(def)
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	return slotTypes[slot] + ":" + name
+}
+
+// FromMemberMeta reads the loadout a party member advertises in
+// Default:AthenaCosmeticLoadout_j.
+func FromMemberMeta(meta map[string]string) (Loadout, bool) {
+	raw, ok := meta["Default:AthenaCosmeticLoadout_j"]
+	if !ok {
+		return nil, false
+	}
+	var wrapper struct {
+		AthenaCosmeticLoadout map[string]interface{} `json:"AthenaCosmeticLoadout"`
+	}
+	if err := json.Unmarshal([]byte(raw), &wrapper); err != nil || wrapper.AthenaCosmeticLoadout == nil {
+		return nil, false
+	}
+	fields := wrapper.AthenaCosmeticLoadout
+
+	def := func(keys ...string) string {
+		for _, key := range keys {
+			if s, ok := fields[key].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+
+	loadout := Loadout{}
+	for slot, keys := range map[string][]string{
+		"Character":       {"characterPrimaryAssetId", "characterDef"},
+		"Backpack":        {"backpackDef"},
+		"Pickaxe":         {"pickaxeDef"},
+		"SkyDiveContrail": {"contrailDef"},
+	} {
+		if id := templateFromDef(slot, def(keys...)); id != "" {
+			loadout[slot] = []string{id}
+		}
+	}
+	if len(loadout) == 0 {
+		return nil, false
+	}
+	return loadout, true
+}
+
+// FromProfile reads the loadout last applied in an athena profile.
+func FromProfile(data *profile.ProfileData) (Loadout, bool) {
+	itemId := sandboxLoadout
+	if last, ok := data.Stats.Attributes["last_applied_loadout"].(string); ok && data.Items[last] != nil {
+		itemId = last
+	}
+	item := data.Items[itemId]
+	if item == nil {
+		return nil, false
+	}
+
+	slotsData, _ := item.Attributes["locker_slots_data"].(map[string]interface{})
+	slots, _ := slotsData["slots"].(map[string]interface{})
+	loadout := Loadout{}
+	for name, raw := range slots {
+		slot, _ := raw.(map[string]interface{})
+		items, _ := slot["items"].([]interface{})
+		for _, it := range items {
+			id, _ := it.(string)
+			loadout[name] = append(loadout[name], id)
+		}
+	}
+	if len(loadout) == 0 {
+		return nil, false
+	}
+	return loadout, true
+}
+
+// MemberLoadout is what a party member currently wears: the loadout in their
+// member meta, or else the one saved in their athena profile.
+func MemberLoadout(p *party.Party, accountId string) (Loadout, error) {
+	m := p.Member(accountId)
+	if m == nil {
+		return nil, party.ErrMemberNotFound
+	}
+	if loadout, ok := FromMemberMeta(m.Meta); ok {
+		return loadout, nil
+	}
+	data, err := profile.ReadProfile(accountId, "athena")
+	if err != nil {
+		return nil, ErrNoLoadout
+	}
+	if loadout, ok := FromProfile(data); ok {
+		return loadout, nil
+	}
+	return nil, ErrNoLoadout
+}
+
+// CopyResult lists what Copy equipped, granted first, or had to leave out
+// because the account does not own it.
+type CopyResult struct {
+	Equipped []string `json:"equipped"`
+	Granted  []string `json:"granted"`
+	Skipped  []string `json:"skipped"`
+}
+
+func ownedKey(data *profile.ProfileData, templateId string) (string, bool) {
+	if data.Items[templateId] != nil {
+		return templateId, true
+	}
+	for key, item := range data.Items {
+		if strings.EqualFold(key, templateId) || strings.EqualFold(item.TemplateID, templateId) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// Copy equips loadout in the account's sandbox_loadout. Items the account
+// does not own are granted when the lockerCopyGrantsItems option is on and
+// skipped otherwise; their slots keep what was there.
+func Copy(accountId string, loadout Loadout) (CopyResult, error) {
+	grant := structs.Settings.LockerCopyGrantsItems
+	result := CopyResult{Equipped: []string{}, Granted: []string{}, Skipped: []string{}}
+
+	_, err := profile.Update(accountId, "athena", func(data *profile.ProfileData) error {
+		var changes []interface{}
+		locker := data.Items[sandboxLoadout]
+		if locker == nil {
+			return ErrNoLoadout
+		}
+		slotsData, _ := locker.Attributes["locker_slots_data"].(map[string]interface{})
+		slots, _ := slotsData["slots"].(map[string]interface{})
+		if slots == nil {
+			return ErrNoLoadout
+		}
+
+		names := make([]string, 0, len(loadout))
+		for name := range loadout {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			ids := loadout[name]
+			slot, _ := slots[name].(map[string]interface{})
+			if slot == nil {
+				slot = map[string]interface{}{}
+				slots[name] = slot
+			}
+			current, _ := slot["items"].([]interface{})
+			changed := false
+
+			for i, id := range ids {
+				if id == "" {
+					continue
+				}
+				key, owned := ownedKey(data, id)
+				if !owned {
+					if !grant {
+						result.Skipped = append(result.Skipped, id)
+						continue
+					}
+					// Party meta ids may be lower case; grant the
+					// catalog's spelling when it knows the item.
+					if c, ok := cosmetics.Get(id); ok {
+						id = c.TemplateID()
+					}
+					key = id
+					profile.AddItem(data, key, profile.NewAthenaItem(id), &changes)
+					result.Granted = append(result.Granted, id)
+				}
+				for len(current) <= i {
+					current = append(current, "")
+				}
+				current[i] = key
+				changed = true
+				result.Equipped = append(result.Equipped, key)
+			}
+			slot["items"] = current
+			// Variants belong to the item that was there before.
+			if _, ok := slot["activeVariants"]; ok && changed && len(ids) == 1 {
+				slot["activeVariants"] = []interface{}{map[string]interface{}{"variants": []interface{}{}}}
+			}
+		}
+		if len(result.Equipped) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	if errors.Is(err, errUnchanged) {
+		err = nil
+	}
+	return result, err
+}
@@ -3,8 +3,15 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"neonite-go/bot"
+	"neonite-go/keychain"
+	"neonite-go/matchmaking"
+	"neonite-go/party"
 	"neonite-go/routes"
+	"neonite-go/routes/xmpp"
 	"neonite-go/structs"
 
 	"github.com/gorilla/mux"
@@ -13,11 +20,19 @@ import (
 var version = "1.0"
 
 func main() {
-	port := "3551"
+	if err := structs.LoadConfig("config/config.json"); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	structs.NeoLog("Starting server...")
+	keychain.Load()
+	port := structs.Settings.Port
 
 	r := mux.NewRouter()
 	r.Use(jsonMiddleware)
+	r.Use(structs.BuildInfoMiddleware)
 
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -29,6 +44,33 @@ func main() {
 	routes.RegisterStorefrontRoutes(r)
 	routes.RegisterLightswitchRoutes(r)
 	routes.RegisterPermission(r)
+	routes.RegisterSearchRoutes(r)
+	routes.RegisterFriendsRoutes(r)
+	routes.RegisterPartyRoutes(r)
+	routes.RegisterPresenceRoutes(r)
+	routes.RegisterLockerRoutes(r)
+	routes.RegisterTimelineRoutes(r)
+	routes.RegisterCloudStorageRoutes(r)
+	routes.RegisterContentRoutes(r)
+	routes.RegisterEOSRoutes(r)
+	routes.RegisterMatchmakingRoutes(r)
+
+	xmpp.WatchParties()
+	party.StartSweeper(30 * time.Second)
+	matchmaking.StartSweeper(15 * time.Second)
+	bot.Start()
+
+	go func() {
+		if err := xmpp.ListenAndServe(":" + structs.Settings.XmppPort); err != nil {
+			structs.NeoLog("[XMPP] Server failed: " + err.Error())
+		}
+	}()
+	go func() {
+		addr := ":" + structs.Settings.XmppTcpPort
+		if err := xmpp.ListenAndServeTCP(addr, structs.Settings.XmppCertFile, structs.Settings.XmppKeyFile); err != nil {
+			structs.NeoLog("[XMPP] TCP server failed: " + err.Error())
+		}
+	}()
 
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		structs.SendError(w, http.StatusNotFound, "not_found")
@@ -0,0 +1,146 @@
+package matchmaking
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"neonite-go/structs"
+)
+
+func TestVerify(t *testing.T) {
+	ticket, err := NewTicket("player", nil, "1:0:NAE:playlist_defaultsolo", "WIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature := ticket.Sign()
+
+	expired := ticket
+	expired.Expires = time.Now().Add(-time.Second)
+	expiredPayload, expiredSignature := expired.Sign()
+
+	other := ticket
+	other.AccountID = "someone-else"
+	otherPayload, _ := other.Sign()
+
+	tests := []struct {
+		name, payload, signature string
+		want                     error
+	}{
+		{"genuine", payload, signature, nil},
+		{"other payload", otherPayload, signature, ErrBadSignature},
+		{"bad signature", payload, "AAAA", ErrBadSignature},
+		{"no signature", payload, "", ErrBadSignature},
+		{"not base64", "!!!", sign("!!!"), ErrBadSignature},
+		{"expired", expiredPayload, expiredSignature, ErrExpired},
+	}
+	for _, tt := range tests {
+		got, err := Verify(tt.payload, tt.signature)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify error = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && got.AccountID != "player" {
+			t.Errorf("%s: Verify = ticket for %s", tt.name, got.AccountID)
+		}
+	}
+}
+
+func TestClaim(t *testing.T) {
+	ticket, _ := NewTicket("claimer", nil, "1:0:NAE:playlist_defaultsolo", "WIN")
+	if err := Claim(ticket); err != nil {
+		t.Fatalf("first Claim = %v", err)
+	}
+	if err := Claim(ticket); !errors.Is(err, ErrTicketUsed) {
+		t.Fatalf("second Claim = %v, want %v", err, ErrTicketUsed)
+	}
+}
+
+// resetPool empties the pool and loads the given static servers.
+func resetPool(t *testing.T, static ...structs.GameServerConfig) {
+	t.Helper()
+	old := structs.Settings.Matchmaking.Servers
+	structs.Settings.Matchmaking.Servers = static
+	poolMu.Lock()
+	servers, poolLoaded = nil, false
+	poolMu.Unlock()
+	t.Cleanup(func() { structs.Settings.Matchmaking.Servers = old })
+}
+
+func TestAssignRelease(t *testing.T) {
+	resetPool(t,
+		structs.GameServerConfig{IP: "127.0.0.1", Port: 7777, Capacity: 3},
+		structs.GameServerConfig{IP: "127.0.0.1", Port: 7778, Capacity: 3},
+	)
+	a, _ := NewTicket("a", []string{"b"}, "1:0:NAE:playlist_defaultduo", "WIN")
+	b, _ := NewTicket("b", []string{"a"}, "1:0:NAE:playlist_defaultduo", "WIN")
+	solo, _ := NewTicket("c", nil, "1:0:NAE:playlist_defaultduo", "WIN")
+
+	first, ok := Assign(a)
+	if !ok || len(first.Players) != 1 || first.Players[0] != "a" {
+		t.Fatalf("Assign(a) = %+v, %v", first, ok)
+	}
+	second, ok := Assign(b)
+	if !ok || second.SessionID != first.SessionID || len(second.Players) != 2 {
+		t.Fatalf("Assign(b) = %+v, %v, want b next to a", second, ok)
+	}
+	if again, _ := Assign(a); len(again.Players) != 2 {
+		t.Fatalf("a took a second slot: %v", again.Players)
+	}
+
+	Release(a, first.SessionID)
+	Release(b, first.SessionID)
+	for _, s := range Servers() {
+		if len(s.Players) != 0 {
+			t.Fatalf("players after Release = %v", s.Players)
+		}
+	}
+	if _, ok := Assign(solo); !ok {
+		t.Fatal("Assign(solo) found no room after Release")
+	}
+}
+
+func TestHeartbeatFreesStaticServer(t *testing.T) {
+	resetPool(t, structs.GameServerConfig{IP: "127.0.0.1", Port: 7777, Capacity: 1})
+	ticket, _ := NewTicket("a", nil, "1:0:NAE:playlist_defaultsolo", "WIN")
+	other, _ := NewTicket("b", nil, "2:0:NAE:playlist_defaultsolo", "WIN")
+	a, ok := Assign(ticket)
+	if !ok || a.NetCL != "1" {
+		t.Fatalf("Assign = %+v, %v", a, ok)
+	}
+
+	steps := []struct {
+		state   string
+		players int
+		netCL   string
+	}{
+		{StateStarted, 1, "1"},
+		{StateClosed, 1, "1"},
+		{StateOpen, 0, ""},
+		{StateOpen, 0, ""},
+	}
+	for _, step := range steps {
+		s, err := Heartbeat(a.SessionID, step.state, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Players) != step.players || s.NetCL != step.netCL {
+			t.Errorf("after %s: %d players on %q, want %d on %q", step.state, len(s.Players), s.NetCL, step.players, step.netCL)
+		}
+	}
+	if _, ok := Assign(other); !ok {
+		t.Error("the static server took no players of another build after its match ended")
+	}
+}
+
+func TestHeartbeatKeepsRegisteredBuild(t *testing.T) {
+	resetPool(t)
+	s, err := Register(Server{IP: "127.0.0.1", Port: 7777, Capacity: 1, NetCL: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Heartbeat(s.SessionID, StateStarted, nil)
+	if s, _ = Heartbeat(s.SessionID, StateOpen, nil); s.NetCL != "1" {
+		t.Errorf("NetCL after the match = %q, want %q", s.NetCL, "1")
+	}
+}
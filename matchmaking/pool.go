@@ -0,0 +1,353 @@
+package matchmaking
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"neonite-go/structs"
+
+	"github.com/google/uuid"
+)
+
+// defaultCapacity is used for servers configured or registered without one.
+const defaultCapacity = 100
+
+// Server states. Only open servers are sent players.
+const (
+	StateOpen    = "open"
+	StateStarted = "started"
+	StateClosed  = "closed"
+)
+
+var (
+	ErrInvalidServer  = errors.New("invalid_server")
+	ErrInvalidState   = errors.New("invalid_server_state")
+	ErrServerNotFound = errors.New("session_not_found")
+)
+
+// Server is a game server in the pool along with the players sent to it.
+// Servers from the config are Static and stay until restart; registered
+// ones are dropped when their heartbeats stop.
+type Server struct {
+	SessionID     string    `json:"sessionId"`
+	IP            string    `json:"ip"`
+	Port          int       `json:"port"`
+	Playlist      string    `json:"playlist"`
+	Region        string    `json:"region"`
+	Capacity      int       `json:"capacity"`
+	State         string    `json:"state"`
+	NetCL         string    `json:"netCL"`
+	Players       []string  `json:"players"`
+	Static        bool      `json:"static"`
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitzero"`
+
+	// build is the NetCL the server registered with. Otherwise NetCL is
+	// taken from the first ticket of each match.
+	build string
+}
+
+// Free is how many more players the server takes.
+func (s Server) Free() int {
+	return s.Capacity - len(s.Players)
+}
+
+// has reports whether the server counts the player as one of its own.
+func (s Server) has(accountId string) bool {
+	for _, id := range s.Players {
+		if id == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the server is open for the ticket's playlist,
+// region and build.
+func (s Server) matches(t Ticket) bool {
+	if s.State != StateOpen {
+		return false
+	}
+	if s.Playlist != "" && !strings.EqualFold(s.Playlist, t.Playlist) {
+		return false
+	}
+	if s.Region != "" && !strings.EqualFold(s.Region, t.Region) {
+		return false
+	}
+	return s.NetCL == "" || t.NetCL == "" || s.NetCL == t.NetCL
+}
+
+// accepts reports whether the ticket's whole party fits on the server.
+func (s Server) accepts(t Ticket) bool {
+	return s.matches(t) && s.Free() >= t.Players()
+}
+
+func (s *Server) copy() Server {
+	c := *s
+	c.Players = append([]string{}, s.Players...)
+	return c
+}
+
+// Assignment is the session a ticket was matched into.
+type Assignment struct {
+	MatchID string `json:"matchId"`
+	Server
+}
+
+var (
+	poolMu     sync.Mutex
+	servers    []*Server
+	poolLoaded bool
+)
+
+func newSessionID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// loadPool fills the pool from the config the first time it is needed.
+// Callers must hold poolMu.
+func loadPool() {
+	if poolLoaded {
+		return
+	}
+	poolLoaded = true
+	for _, c := range structs.Settings.Matchmaking.Servers {
+		if c.IP == "" || c.Port <= 0 {
+			structs.NeoLog("[Matchmaking] Ignoring a game server without an address")
+			continue
+		}
+		capacity := c.Capacity
+		if capacity <= 0 {
+			capacity = defaultCapacity
+		}
+		servers = append(servers, &Server{
+			SessionID: newSessionID(),
+			IP:        c.IP,
+			Port:      c.Port,
+			Playlist:  c.Playlist,
+			Region:    c.Region,
+			Capacity:  capacity,
+			State:     StateOpen,
+			Static:    true,
+		})
+	}
+}
+
+// find returns the server running a session. Callers must hold poolMu.
+func find(sessionId string) *Server {
+	for _, s := range servers {
+		if s.SessionID == sessionId {
+			return s
+		}
+	}
+	return nil
+}
+
+// Servers returns the game servers in the pool.
+func Servers() []Server {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	loadPool()
+	list := make([]Server, len(servers))
+	for i, s := range servers {
+		list[i] = s.copy()
+	}
+	return list
+}
+
+// Session returns the server running a session.
+func Session(sessionId string) (Server, error) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	loadPool()
+	s := find(sessionId)
+	if s == nil {
+		return Server{}, ErrServerNotFound
+	}
+	return s.copy(), nil
+}
+
+// Register adds a dedicated server to the pool with a new session and no
+// players. A server registering again from the same address replaces its
+// old entry, as it does when it restarts for the next match.
+func Register(s Server) (Server, error) {
+	if s.IP == "" || s.Port <= 0 || s.Port > 65535 || s.Capacity < 0 {
+		return Server{}, ErrInvalidServer
+	}
+	if s.State == "" {
+		s.State = StateOpen
+	}
+	if !validState(s.State) {
+		return Server{}, ErrInvalidState
+	}
+	if s.Capacity == 0 {
+		s.Capacity = defaultCapacity
+	}
+	s.SessionID = newSessionID()
+	s.build = s.NetCL
+	s.Players = []string{}
+	s.Static = false
+	s.LastHeartbeat = time.Now().UTC()
+
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	loadPool()
+	kept := servers[:0]
+	for _, old := range servers {
+		if old.IP != s.IP || old.Port != s.Port {
+			kept = append(kept, old)
+		}
+	}
+	servers = append(kept, &s)
+	structs.NeoLog("[Matchmaking] Game server " + s.SessionID + " registered")
+	return s.copy(), nil
+}
+
+func validState(state string) bool {
+	return state == StateOpen || state == StateStarted || state == StateClosed
+}
+
+// Heartbeat keeps a registered server in the pool. A non-empty state
+// replaces the server's, and players, when not nil, replaces the list of
+// players the server counts as taken. A server going back to open after a
+// match starts the next one empty unless players says otherwise, and
+// forgets the build the last match was for; static servers, which never
+// register again, rely on this to free their slots.
+func Heartbeat(sessionId, state string, players []string) (Server, error) {
+	if state != "" && !validState(state) {
+		return Server{}, ErrInvalidState
+	}
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	loadPool()
+	s := find(sessionId)
+	if s == nil {
+		return Server{}, ErrServerNotFound
+	}
+	if state == StateOpen && s.State != StateOpen {
+		s.Players = []string{}
+		s.NetCL = s.build
+	}
+	if state != "" {
+		s.State = state
+	}
+	if players != nil {
+		s.Players = append([]string{}, players...)
+	}
+	s.LastHeartbeat = time.Now().UTC()
+	return s.copy(), nil
+}
+
+// Unregister takes a server out of the pool.
+func Unregister(sessionId string) error {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	loadPool()
+	for i, s := range servers {
+		if s.SessionID == sessionId {
+			servers = append(servers[:i], servers[i+1:]...)
+			return nil
+		}
+	}
+	return ErrServerNotFound
+}
+
+// heartbeatTimeout is how long a registered server is kept without a
+// heartbeat.
+func heartbeatTimeout() time.Duration {
+	if secs := structs.Settings.Matchmaking.HeartbeatTimeout; secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return 90 * time.Second
+}
+
+// Sweep drops the registered servers whose heartbeats stopped before now.
+func Sweep(now time.Time) {
+	timeout := heartbeatTimeout()
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	kept := servers[:0]
+	for _, s := range servers {
+		if !s.Static && now.Sub(s.LastHeartbeat) > timeout {
+			structs.NeoLog("[Matchmaking] Dropping game server " + s.SessionID + ": no heartbeat")
+			continue
+		}
+		kept = append(kept, s)
+	}
+	servers = kept
+}
+
+// StartSweeper runs Sweep in the background every interval.
+func StartSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			Sweep(now)
+		}
+	}()
+}
+
+// partyServer returns the server one of the ticket's party members was
+// already sent to, if it has room for the ticket's player too. Callers must
+// hold poolMu.
+func partyServer(t Ticket) *Server {
+	for _, s := range servers {
+		if !s.matches(t) || (s.Free() < 1 && !s.has(t.AccountID)) {
+			continue
+		}
+		for _, id := range t.PartyPlayerIDs {
+			if id != t.AccountID && s.has(id) {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// Assign takes a slot for the ticket's player. Every party member queues
+// with a ticket of their own, so each only reserves their own slot: the
+// first goes to the fullest server that still has room for the whole party,
+// so matches fill up before new ones start, and the others follow them
+// there. It reports false when no server can take the player.
+func Assign(t Ticket) (Assignment, bool) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	loadPool()
+	best := partyServer(t)
+	if best == nil {
+		for _, s := range servers {
+			if s.accepts(t) && (best == nil || s.Free() < best.Free()) {
+				best = s
+			}
+		}
+	}
+	if best == nil {
+		return Assignment{}, false
+	}
+	if !best.has(t.AccountID) {
+		best.Players = append(best.Players, t.AccountID)
+	}
+	if best.NetCL == "" {
+		best.NetCL = t.NetCL
+	}
+	return Assignment{MatchID: t.ID, Server: best.copy()}, true
+}
+
+// Release gives back the slot Assign took for the ticket's player on a
+// session, for a player who left before being told to join it.
+func Release(t Ticket, sessionId string) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	s := find(sessionId)
+	if s == nil {
+		return
+	}
+	for i, id := range s.Players {
+		if id == t.AccountID {
+			s.Players = append(s.Players[:i], s.Players[i+1:]...)
+			break
+		}
+	}
+}
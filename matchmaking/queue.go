@@ -0,0 +1,63 @@
+package matchmaking
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrTicketUsed = errors.New("ticket_already_used")
+
+var (
+	queueMu sync.Mutex
+	queue   = make(map[string]Ticket)
+	claimed = make(map[string]time.Time) // ticket id -> expiry
+)
+
+// Claim marks the ticket as used, so it gets the player one reservation at
+// most. A ticket is only remembered until it expires, since Verify refuses
+// it from then on.
+func Claim(t Ticket) error {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	now := time.Now()
+	for id, expires := range claimed {
+		if now.After(expires) {
+			delete(claimed, id)
+		}
+	}
+	if _, ok := claimed[t.ID]; ok {
+		return ErrTicketUsed
+	}
+	claimed[t.ID] = t.Expires
+	return nil
+}
+
+// Enqueue puts the ticket in the queue until Dequeue is called.
+func Enqueue(t Ticket) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	queue[t.ID] = t
+}
+
+// Dequeue takes the ticket out of the queue.
+func Dequeue(t Ticket) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	delete(queue, t.ID)
+}
+
+// Queued counts the players waiting for the same playlist and region as
+// the ticket, its own party included.
+func Queued(t Ticket) int {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	n := 0
+	for _, q := range queue {
+		if strings.EqualFold(q.Playlist, t.Playlist) && strings.EqualFold(q.Region, t.Region) {
+			n += q.Players()
+		}
+	}
+	return n
+}
@@ -0,0 +1,115 @@
+package matchmaking
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TicketLifetime is how long a ticket can be used to join the queue.
+const TicketLifetime = 5 * time.Minute
+
+var (
+	ErrInvalidBucket = errors.New("invalid_bucket_id")
+	ErrBadSignature  = errors.New("invalid_ticket_signature")
+	ErrExpired       = errors.New("ticket_expired")
+)
+
+// Ticket is what the client presents to the matchmaker: who is queueing,
+// with which party, for what.
+type Ticket struct {
+	ID             string    `json:"ticketId"`
+	AccountID      string    `json:"playerId"`
+	PartyPlayerIDs []string  `json:"partyPlayerIds"`
+	BucketID       string    `json:"bucketId"`
+	NetCL          string    `json:"netCL"`
+	Region         string    `json:"region"`
+	Playlist       string    `json:"playlist"`
+	Platform       string    `json:"platform"`
+	Issued         time.Time `json:"issued"`
+	Expires        time.Time `json:"expires"`
+}
+
+// Players is how many slots the ticket needs: the player and their party.
+func (t Ticket) Players() int {
+	return max(len(t.PartyPlayerIDs), 1)
+}
+
+// NewTicket builds a ticket from the bucket id the client sends, which has
+// the form "netCL:hotfixVersion:region:playlist".
+func NewTicket(accountId string, partyPlayerIds []string, bucketId, platform string) (Ticket, error) {
+	parts := strings.Split(bucketId, ":")
+	if len(parts) != 4 || parts[2] == "" || parts[3] == "" {
+		return Ticket{}, ErrInvalidBucket
+	}
+	party := make([]string, 0, len(partyPlayerIds)+1)
+	party = append(party, accountId)
+	for _, id := range partyPlayerIds {
+		if id != "" && id != accountId {
+			party = append(party, id)
+		}
+	}
+
+	now := time.Now().UTC()
+	return Ticket{
+		ID:             strings.ReplaceAll(uuid.New().String(), "-", ""),
+		AccountID:      accountId,
+		PartyPlayerIDs: party,
+		BucketID:       bucketId,
+		NetCL:          parts[0],
+		Region:         parts[2],
+		Playlist:       parts[3],
+		Platform:       platform,
+		Issued:         now,
+		Expires:        now.Add(TicketLifetime),
+	}, nil
+}
+
+// signingKey signs the tickets. Only this server reads them back, so a key
+// per run is enough.
+var signingKey = func() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign encodes the ticket as the payload and signature the client passes to
+// the matchmaker.
+func (t Ticket) Sign() (payload, signature string) {
+	data, _ := json.Marshal(t)
+	payload = base64.StdEncoding.EncodeToString(data)
+	return payload, sign(payload)
+}
+
+// Verify checks a signed ticket and returns it if it is genuine and has not
+// expired.
+func Verify(payload, signature string) (Ticket, error) {
+	if !hmac.Equal([]byte(sign(payload)), []byte(signature)) {
+		return Ticket{}, ErrBadSignature
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return Ticket{}, ErrBadSignature
+	}
+	var t Ticket
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Ticket{}, ErrBadSignature
+	}
+	if time.Now().After(t.Expires) {
+		return Ticket{}, ErrExpired
+	}
+	return t, nil
+}
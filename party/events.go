@@ -0,0 +1,79 @@
+package party
+
+import (
+	"sync"
+)
+
+const (
+	EventMemberJoined       = "MEMBER_JOINED"
+	EventMemberLeft         = "MEMBER_LEFT"
+	EventMemberKicked       = "MEMBER_KICKED"
+	EventMemberNewCaptain   = "MEMBER_NEW_CAPTAIN"
+	EventMemberStateUpdated = "MEMBER_STATE_UPDATED"
+	EventPartyUpdated       = "PARTY_UPDATED"
+	EventInitialInvite      = "INITIAL_INVITE"
+	EventInviteCancelled    = "INVITE_CANCELLED"
+	EventInviteDeclined     = "INVITE_DECLINED"
+	EventPing               = "PING"
+	EventInitialIntention   = "INITIAL_INTENTION"
+)
+
+// Event describes one party state change. Party is a snapshot taken right
+// after the change; Member is the affected member, taken before removal for
+// leave and kick events.
+type Event struct {
+	Type      string
+	Party     *Party
+	Member    *Member
+	Updated   map[string]string
+	Removed   []string
+	Invite    *Invite
+	Ping      *Ping
+	Intention *Intention
+}
+
+var (
+	queueMu   sync.Mutex
+	queueCond = sync.NewCond(&queueMu)
+	queue     []Event
+	listeners []func(Event)
+	startOnce sync.Once
+)
+
+// Subscribe registers fn to be called, in order, for every party event.
+// Listeners run on a separate goroutine, so they may call back into this
+// package.
+func Subscribe(fn func(Event)) {
+	queueMu.Lock()
+	listeners = append(listeners, fn)
+	queueMu.Unlock()
+
+	startOnce.Do(func() { go dispatch() })
+}
+
+func emit(events ...Event) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if len(listeners) == 0 {
+		return
+	}
+	queue = append(queue, events...)
+	queueCond.Signal()
+}
+
+func dispatch() {
+	for {
+		queueMu.Lock()
+		for len(queue) == 0 {
+			queueCond.Wait()
+		}
+		e := queue[0]
+		queue = queue[1:]
+		fns := append([]func(Event){}, listeners...)
+		queueMu.Unlock()
+
+		for _, fn := range fns {
+			fn(e)
+		}
+	}
+}
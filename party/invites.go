@@ -0,0 +1,414 @@
+package party
+
+import (
+	"time"
+)
+
+const InviteStatusSent = "SENT"
+
+type Invite struct {
+	PartyID   string            `json:"party_id"`
+	SentBy    string            `json:"sent_by"`
+	Meta      map[string]string `json:"meta"`
+	SentTo    string            `json:"sent_to"`
+	SentAt    time.Time         `json:"sent_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Status    string            `json:"status"`
+}
+
+type Ping struct {
+	SentBy    string            `json:"sent_by"`
+	SentTo    string            `json:"sent_to"`
+	SentAt    time.Time         `json:"sent_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Meta      map[string]string `json:"meta"`
+}
+
+// Intention is a request from RequesterID to join RequesteeID's party.
+type Intention struct {
+	RequesterID   string            `json:"requester_id"`
+	RequesterDN   string            `json:"requester_dn"`
+	RequesterPl   string            `json:"requester_pl"`
+	RequesterPlDN string            `json:"requester_pl_dn"`
+	RequesteeID   string            `json:"requestee_id"`
+	Meta          map[string]string `json:"meta"`
+	ExpiresAt     time.Time         `json:"expires_at"`
+	SentAt        time.Time         `json:"sent_at"`
+}
+
+var (
+	pings      = make(map[string][]*Ping)
+	intentions []*Intention
+)
+
+func (i *Invite) clone() *Invite {
+	c := *i
+	c.Meta = copyMeta(i.Meta)
+	return &c
+}
+
+func (p *Ping) clone() *Ping {
+	c := *p
+	c.Meta = copyMeta(p.Meta)
+	return &c
+}
+
+func (i *Intention) clone() *Intention {
+	c := *i
+	c.Meta = copyMeta(i.Meta)
+	return &c
+}
+
+func ttl(seconds, fallback int) time.Duration {
+	if seconds <= 0 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// canJoin reports whether the party's joinability lets the account in.
+// Callers must hold mu.
+func canJoin(p *Party, accountId string, now time.Time) bool {
+	switch p.Config.Joinability {
+	case JoinabilityInviteOnly:
+		return isInvited(p, accountId, now)
+	case JoinabilityInviteAndFormer:
+		return p.formerMembers[accountId] || isInvited(p, accountId, now)
+	default:
+		return true
+	}
+}
+
+func isInvited(p *Party, accountId string, now time.Time) bool {
+	for _, inv := range p.Invites {
+		if inv.SentTo == accountId && inv.ExpiresAt.After(now) {
+			return true
+		}
+	}
+	for _, ping := range pings[accountId] {
+		if ping.ExpiresAt.After(now) && p.Member(ping.SentBy) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// consumeInvites drops the invites, pings and join requests that led the
+// account into the party. Callers must hold mu.
+func consumeInvites(p *Party, accountId string) {
+	kept := p.Invites[:0]
+	for _, inv := range p.Invites {
+		if inv.SentTo != accountId {
+			kept = append(kept, inv)
+		}
+	}
+	p.Invites = kept
+
+	var keptPings []*Ping
+	for _, ping := range pings[accountId] {
+		if p.Member(ping.SentBy) == nil {
+			keptPings = append(keptPings, ping)
+		}
+	}
+	setPings(accountId, keptPings)
+
+	var keptIntentions []*Intention
+	for _, in := range intentions {
+		if in.RequesterID != accountId || p.Member(in.RequesteeID) == nil {
+			keptIntentions = append(keptIntentions, in)
+		}
+	}
+	intentions = keptIntentions
+}
+
+func setPings(accountId string, list []*Ping) {
+	if len(list) == 0 {
+		delete(pings, accountId)
+	} else {
+		pings[accountId] = list
+	}
+}
+
+// SendInvite invites an account into the party on behalf of a member. A
+// repeated invite is refreshed.
+func SendInvite(partyId, byAccountId, accountId string, meta map[string]string) (*Invite, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	if p.Member(byAccountId) == nil || p.Member(accountId) != nil {
+		return nil, ErrForbidden
+	}
+
+	now := time.Now().UTC()
+	inv := &Invite{
+		PartyID:   p.ID,
+		SentBy:    byAccountId,
+		Meta:      copyMeta(meta),
+		SentTo:    accountId,
+		SentAt:    now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(ttl(p.Config.InviteTTL, DefaultInviteTTL)),
+		Status:    InviteStatusSent,
+	}
+
+	replaced := false
+	for i, existing := range p.Invites {
+		if existing.SentTo == accountId {
+			inv.SentAt = existing.SentAt
+			p.Invites[i] = inv
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		p.Invites = append(p.Invites, inv)
+	}
+
+	emit(Event{Type: EventInitialInvite, Party: p.clone(), Invite: inv.clone()})
+	return inv.clone(), nil
+}
+
+// CancelInvite withdraws the invite sent to an account.
+func CancelInvite(partyId, accountId string) (*Invite, error) {
+	return removeInvite(partyId, accountId, EventInviteCancelled)
+}
+
+// DeclineInvite is the invitee turning the invite down.
+func DeclineInvite(partyId, accountId string) (*Invite, error) {
+	return removeInvite(partyId, accountId, EventInviteDeclined)
+}
+
+func removeInvite(partyId, accountId, eventType string) (*Invite, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	for i, inv := range p.Invites {
+		if inv.SentTo == accountId {
+			p.Invites = append(p.Invites[:i], p.Invites[i+1:]...)
+			emit(Event{Type: eventType, Party: p.clone(), Invite: inv.clone()})
+			return inv.clone(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UserInvites returns the unexpired invites an account has received.
+func UserInvites(accountId string) []*Invite {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	out := []*Invite{}
+	for _, p := range parties {
+		for _, inv := range p.Invites {
+			if inv.SentTo == accountId && inv.ExpiresAt.After(now) {
+				out = append(out, inv.clone())
+			}
+		}
+	}
+	return out
+}
+
+// SendPing lets pingerId ask accountId to join, using the pinger's party
+// invite TTL when it is in one.
+func SendPing(accountId, pingerId string, meta map[string]string) *Ping {
+	mu.Lock()
+	defer mu.Unlock()
+
+	expiry := ttl(0, DefaultInviteTTL)
+	pingerParty := parties[userParty[pingerId]]
+	if pingerParty != nil {
+		expiry = ttl(pingerParty.Config.InviteTTL, DefaultInviteTTL)
+	}
+
+	now := time.Now().UTC()
+	ping := &Ping{
+		SentBy:    pingerId,
+		SentTo:    accountId,
+		SentAt:    now,
+		ExpiresAt: now.Add(expiry),
+		Meta:      copyMeta(meta),
+	}
+
+	list := []*Ping{ping}
+	for _, existing := range pings[accountId] {
+		if existing.SentBy != pingerId {
+			list = append(list, existing)
+		}
+	}
+	setPings(accountId, list)
+
+	e := Event{Type: EventPing, Ping: ping.clone()}
+	if pingerParty != nil {
+		e.Party = pingerParty.clone()
+	}
+	emit(e)
+	return ping.clone()
+}
+
+func RemovePing(accountId, pingerId string) (*Ping, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := pings[accountId]
+	for i, ping := range list {
+		if ping.SentBy == pingerId {
+			setPings(accountId, append(list[:i], list[i+1:]...))
+			return ping.clone(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UserPings returns the unexpired pings an account has received.
+func UserPings(accountId string) []*Ping {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	out := []*Ping{}
+	for _, ping := range pings[accountId] {
+		if ping.ExpiresAt.After(now) {
+			out = append(out, ping.clone())
+		}
+	}
+	return out
+}
+
+// PingerParty returns the party of the account that pinged accountId, as long
+// as the ping is still valid.
+func PingerParty(accountId, pingerId string) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	for _, ping := range pings[accountId] {
+		if ping.SentBy == pingerId && ping.ExpiresAt.After(now) {
+			p := parties[userParty[pingerId]]
+			if p == nil {
+				return nil, ErrPartyNotFound
+			}
+			return p.clone(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SendIntention records a request from the requester to join the
+// requestee's party, expiring after that party's intention TTL.
+func SendIntention(in Intention) (*Intention, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[userParty[in.RequesteeID]]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	if p.Member(in.RequesterID) != nil {
+		return nil, ErrForbidden
+	}
+
+	now := time.Now().UTC()
+	stored := in
+	stored.Meta = copyMeta(in.Meta)
+	stored.SentAt = now
+	stored.ExpiresAt = now.Add(ttl(p.Config.IntentionTTL, DefaultIntentionTTL))
+
+	kept := []*Intention{&stored}
+	for _, existing := range intentions {
+		if existing.RequesterID != in.RequesterID || existing.RequesteeID != in.RequesteeID {
+			kept = append(kept, existing)
+		}
+	}
+	intentions = kept
+
+	emit(Event{Type: EventInitialIntention, Party: p.clone(), Intention: stored.clone()})
+	return stored.clone(), nil
+}
+
+func RemoveIntention(requesteeId, requesterId string) (*Intention, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, in := range intentions {
+		if in.RequesteeID == requesteeId && in.RequesterID == requesterId {
+			intentions = append(intentions[:i], intentions[i+1:]...)
+			return in.clone(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Intentions returns unexpired join requests sent by (sent true) or to the
+// account.
+func Intentions(accountId string, sent bool) []*Intention {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	out := []*Intention{}
+	for _, in := range intentions {
+		owner := in.RequesteeID
+		if sent {
+			owner = in.RequesterID
+		}
+		if owner == accountId && in.ExpiresAt.After(now) {
+			out = append(out, in.clone())
+		}
+	}
+	return out
+}
+
+// Sweep drops every invite, ping and join request that expired before now.
+func Sweep(now time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, p := range parties {
+		kept := p.Invites[:0]
+		for _, inv := range p.Invites {
+			if inv.ExpiresAt.After(now) {
+				kept = append(kept, inv)
+			}
+		}
+		p.Invites = kept
+	}
+
+	for accountId, list := range pings {
+		var kept []*Ping
+		for _, ping := range list {
+			if ping.ExpiresAt.After(now) {
+				kept = append(kept, ping)
+			}
+		}
+		setPings(accountId, kept)
+	}
+
+	var kept []*Intention
+	for _, in := range intentions {
+		if in.ExpiresAt.After(now) {
+			kept = append(kept, in)
+		}
+	}
+	intentions = kept
+}
+
+// StartSweeper runs Sweep in the background every interval.
+func StartSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			Sweep(now)
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+package party
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	var join JoinInfo
+	join.Connection.ID = "sweep-captain@prod.ol.epicgames.com/V2:Fortnite"
+	p := Create(Config{InviteTTL: 60, IntentionTTL: 10}, nil, join)
+
+	if _, err := SendInvite(p.ID, "sweep-captain", "sweep-invited", nil); err != nil {
+		t.Fatal(err)
+	}
+	SendPing("sweep-pinged", "sweep-captain", nil)
+	if _, err := SendIntention(Intention{RequesterID: "sweep-requester", RequesteeID: "sweep-captain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() (invites, pings, intentions int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(parties[p.ID].Invites), len(pingsTo("sweep-pinged")), len(intentionsTo("sweep-captain"))
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name                       string
+		at                         time.Time
+		invites, pings, intentions int
+	}{
+		{"before any expiry", now, 1, 1, 1},
+		{"after the intention TTL", now.Add(30 * time.Second), 1, 1, 0},
+		{"after the invite TTL", now.Add(2 * time.Minute), 0, 0, 0},
+	}
+	for _, tt := range tests {
+		Sweep(tt.at)
+		invites, pings, intentions := count()
+		if invites != tt.invites || pings != tt.pings || intentions != tt.intentions {
+			t.Errorf("%s: %d invites, %d pings, %d intentions, want %d, %d, %d",
+				tt.name, invites, pings, intentions, tt.invites, tt.pings, tt.intentions)
+		}
+	}
+	if _, ok := pings["sweep-pinged"]; ok {
+		t.Error("an account with no pings left is still in the ping map")
+	}
+}
+
+// pingsTo and intentionsTo read the stores directly, expired entries
+// included. Callers must hold mu.
+func pingsTo(accountId string) []*Ping {
+	return pings[accountId]
+}
+
+func intentionsTo(accountId string) []*Intention {
+	var out []*Intention
+	for _, in := range intentions {
+		if in.RequesteeID == accountId {
+			out = append(out, in)
+		}
+	}
+	return out
+}
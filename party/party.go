@@ -0,0 +1,494 @@
+package party
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleCaptain = "CAPTAIN"
+	RoleMember  = "MEMBER"
+
+	DefaultMaxSize      = 16
+	DefaultInviteTTL    = 14400
+	DefaultIntentionTTL = 60
+
+	JoinabilityOpen            = "OPEN"
+	JoinabilityInviteOnly      = "INVITE_ONLY"
+	JoinabilityInviteAndFormer = "INVITE_AND_FORMER"
+)
+
+var (
+	ErrPartyNotFound  = errors.New("party_not_found")
+	ErrMemberNotFound = errors.New("member_not_found")
+	ErrPartyFull      = errors.New("party_is_full")
+	ErrStaleRevision  = errors.New("stale_revision")
+	ErrForbidden      = errors.New("party_change_forbidden")
+	ErrNotInvited     = errors.New("party_join_forbidden")
+	ErrNotFound       = errors.New("not_found")
+)
+
+type Config struct {
+	Type             string `json:"type"`
+	Joinability      string `json:"joinability"`
+	Discoverability  string `json:"discoverability"`
+	SubType          string `json:"sub_type"`
+	MaxSize          int    `json:"max_size"`
+	InviteTTL        int    `json:"invite_ttl"`
+	JoinConfirmation bool   `json:"join_confirmation"`
+	IntentionTTL     int    `json:"intention_ttl"`
+}
+
+type Connection struct {
+	ID              string            `json:"id"`
+	ConnectedAt     time.Time         `json:"connected_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	YieldLeadership bool              `json:"yield_leadership"`
+	Meta            map[string]string `json:"meta"`
+}
+
+type Member struct {
+	AccountID   string            `json:"account_id"`
+	Meta        map[string]string `json:"meta"`
+	Connections []Connection      `json:"connections"`
+	Revision    int               `json:"revision"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	JoinedAt    time.Time         `json:"joined_at"`
+	Role        string            `json:"role"`
+}
+
+type Party struct {
+	ID         string            `json:"id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Config     Config            `json:"config"`
+	Members    []*Member         `json:"members"`
+	Applicants []interface{}     `json:"applicants"`
+	Meta       map[string]string `json:"meta"`
+	Invites    []*Invite         `json:"invites"`
+	Revision   int               `json:"revision"`
+
+	formerMembers map[string]bool
+}
+
+// JoinInfo is what a client sends about itself when creating or joining a
+// party.
+type JoinInfo struct {
+	Connection struct {
+		ID              string            `json:"id"`
+		Meta            map[string]string `json:"meta"`
+		YieldLeadership bool              `json:"yield_leadership"`
+	} `json:"connection"`
+	Meta map[string]string `json:"meta"`
+}
+
+// AccountID returns the account part of the connection's JID.
+func (j JoinInfo) AccountID() string {
+	return strings.Split(j.Connection.ID, "@")[0]
+}
+
+var (
+	mu        sync.Mutex
+	parties   = make(map[string]*Party)
+	userParty = make(map[string]string)
+)
+
+func newID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+func newMember(accountId string, join JoinInfo, role string) *Member {
+	now := time.Now().UTC()
+	m := &Member{
+		AccountID:   accountId,
+		Meta:        make(map[string]string),
+		Connections: []Connection{},
+		UpdatedAt:   now,
+		JoinedAt:    now,
+		Role:        role,
+	}
+	for k, v := range join.Meta {
+		m.Meta[k] = v
+	}
+	if join.Connection.ID != "" {
+		connMeta := make(map[string]string)
+		for k, v := range join.Connection.Meta {
+			connMeta[k] = v
+		}
+		m.Connections = append(m.Connections, Connection{
+			ID:              join.Connection.ID,
+			ConnectedAt:     now,
+			UpdatedAt:       now,
+			YieldLeadership: join.Connection.YieldLeadership,
+			Meta:            connMeta,
+		})
+	}
+	return m
+}
+
+func (m *Member) clone() *Member {
+	c := *m
+	c.Meta = make(map[string]string, len(m.Meta))
+	for k, v := range m.Meta {
+		c.Meta[k] = v
+	}
+	c.Connections = make([]Connection, len(m.Connections))
+	for i, conn := range m.Connections {
+		conn.Meta = copyMeta(conn.Meta)
+		c.Connections[i] = conn
+	}
+	return &c
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	out := make(map[string]string, len(meta))
+	for k, v := range meta {
+		out[k] = v
+	}
+	return out
+}
+
+func (p *Party) clone() *Party {
+	c := *p
+	c.Meta = copyMeta(p.Meta)
+	c.Members = make([]*Member, len(p.Members))
+	for i, m := range p.Members {
+		c.Members[i] = m.clone()
+	}
+	c.Applicants = append([]interface{}{}, p.Applicants...)
+	c.Invites = make([]*Invite, len(p.Invites))
+	for i, inv := range p.Invites {
+		c.Invites[i] = inv.clone()
+	}
+	c.formerMembers = nil
+	return &c
+}
+
+// Member returns the party member with the given account, or nil.
+func (p *Party) Member(accountId string) *Member {
+	for _, m := range p.Members {
+		if m.AccountID == accountId {
+			return m
+		}
+	}
+	return nil
+}
+
+// Captain returns the current party leader.
+func (p *Party) Captain() *Member {
+	for _, m := range p.Members {
+		if m.Role == RoleCaptain {
+			return m
+		}
+	}
+	return nil
+}
+
+func (p *Party) removeMember(accountId string) bool {
+	for i, m := range p.Members {
+		if m.AccountID == accountId {
+			p.Members = append(p.Members[:i], p.Members[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Create starts a new party led by the account in join, taking it out of any
+// party it was still in.
+func Create(cfg Config, meta map[string]string, join JoinInfo) *Party {
+	mu.Lock()
+	defer mu.Unlock()
+
+	accountId := join.AccountID()
+	if old, ok := userParty[accountId]; ok {
+		leave(old, accountId, EventMemberLeft)
+	}
+
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.InviteTTL <= 0 {
+		cfg.InviteTTL = DefaultInviteTTL
+	}
+	if cfg.IntentionTTL <= 0 {
+		cfg.IntentionTTL = DefaultIntentionTTL
+	}
+
+	now := time.Now().UTC()
+	p := &Party{
+		ID:         newID(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Config:     cfg,
+		Members:    []*Member{newMember(accountId, join, RoleCaptain)},
+		Applicants: []interface{}{},
+		Meta:       copyMeta(meta),
+		Invites:    []*Invite{},
+
+		formerMembers: make(map[string]bool),
+	}
+	parties[p.ID] = p
+	userParty[accountId] = p.ID
+	return p.clone()
+}
+
+func Get(partyId string) *Party {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil
+	}
+	return p.clone()
+}
+
+// GetUserParty returns the party the account is currently in, or nil.
+func GetUserParty(accountId string) *Party {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[userParty[accountId]]
+	if p == nil {
+		return nil
+	}
+	return p.clone()
+}
+
+// Join adds the account to a party, leaving its current one. Joining a party
+// the account is already in refreshes its connection instead. Parties that are
+// not open need a pending invite or ping, which the join consumes.
+func Join(partyId, accountId string, join JoinInfo) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+
+	if existing := p.Member(accountId); existing != nil {
+		fresh := newMember(accountId, join, existing.Role)
+		if len(fresh.Connections) > 0 {
+			existing.Connections = fresh.Connections
+		}
+		existing.UpdatedAt = fresh.UpdatedAt
+		return p.clone(), nil
+	}
+
+	if len(p.Members) >= p.Config.MaxSize {
+		return nil, ErrPartyFull
+	}
+	if !canJoin(p, accountId, time.Now()) {
+		return nil, ErrNotInvited
+	}
+	consumeInvites(p, accountId)
+
+	if old, ok := userParty[accountId]; ok {
+		leave(old, accountId, EventMemberLeft)
+	}
+
+	m := newMember(accountId, join, RoleMember)
+	p.Members = append(p.Members, m)
+	p.UpdatedAt = time.Now().UTC()
+	userParty[accountId] = p.ID
+
+	snapshot := p.clone()
+	emit(Event{Type: EventMemberJoined, Party: snapshot, Member: m.clone()})
+	return snapshot, nil
+}
+
+// leave removes a member, handing the captain role to the longest standing
+// member and deleting the party once it is empty. eventType tells whether the
+// member left or was kicked. Callers must hold mu.
+func leave(partyId, accountId, eventType string) (*Party, bool) {
+	p := parties[partyId]
+	if p == nil {
+		return nil, false
+	}
+	m := p.Member(accountId)
+	if m == nil {
+		return nil, false
+	}
+	p.removeMember(accountId)
+	if userParty[accountId] == partyId {
+		delete(userParty, accountId)
+	}
+
+	p.formerMembers[accountId] = true
+	p.UpdatedAt = time.Now().UTC()
+
+	if len(p.Members) == 0 {
+		delete(parties, partyId)
+		emit(Event{Type: eventType, Party: p.clone(), Member: m.clone()})
+		return p, true
+	}
+
+	if p.Captain() == nil {
+		p.Members[0].Role = RoleCaptain
+		snapshot := p.clone()
+		emit(
+			Event{Type: eventType, Party: snapshot, Member: m.clone()},
+			Event{Type: EventMemberNewCaptain, Party: snapshot, Member: p.Members[0].clone()},
+		)
+		return p, true
+	}
+
+	emit(Event{Type: eventType, Party: p.clone(), Member: m.clone()})
+	return p, true
+}
+
+// Leave removes the account from the party. byAccountId is the caller; only
+// the captain may remove someone else.
+func Leave(partyId, byAccountId, accountId string) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	if p.Member(accountId) == nil {
+		return nil, ErrMemberNotFound
+	}
+	eventType := EventMemberLeft
+	if byAccountId != accountId {
+		if c := p.Captain(); c == nil || c.AccountID != byAccountId {
+			return nil, ErrForbidden
+		}
+		eventType = EventMemberKicked
+	}
+
+	left, _ := leave(partyId, accountId, eventType)
+	return left.clone(), nil
+}
+
+// Disband removes every member of the party. Only the captain may do this.
+func Disband(partyId, byAccountId string) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	if c := p.Captain(); c == nil || c.AccountID != byAccountId {
+		return nil, ErrForbidden
+	}
+
+	disbanded := p.clone()
+	for _, m := range disbanded.Members {
+		if userParty[m.AccountID] == partyId {
+			delete(userParty, m.AccountID)
+		}
+		emit(Event{Type: EventMemberLeft, Party: disbanded, Member: m})
+	}
+	delete(parties, partyId)
+	return disbanded, nil
+}
+
+// Promote hands the captain role to another member.
+func Promote(partyId, byAccountId, accountId string) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	target := p.Member(accountId)
+	if target == nil {
+		return nil, ErrMemberNotFound
+	}
+	captain := p.Captain()
+	if captain == nil || captain.AccountID != byAccountId {
+		return nil, ErrForbidden
+	}
+
+	captain.Role = RoleMember
+	target.Role = RoleCaptain
+	p.UpdatedAt = time.Now().UTC()
+
+	snapshot := p.clone()
+	emit(Event{Type: EventMemberNewCaptain, Party: snapshot, Member: target.clone()})
+	return snapshot, nil
+}
+
+func applyMeta(meta map[string]string, update map[string]string, remove []string) {
+	for _, key := range remove {
+		delete(meta, key)
+	}
+	for k, v := range update {
+		meta[k] = v
+	}
+}
+
+// UpdateParty applies a captain's party patch. revision must match the
+// party's current revision; config is a partial config object, if any.
+func UpdateParty(partyId, byAccountId string, revision int, config json.RawMessage, update map[string]string, remove []string) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	if c := p.Captain(); c == nil || c.AccountID != byAccountId {
+		return nil, ErrForbidden
+	}
+	if revision != p.Revision {
+		return nil, ErrStaleRevision
+	}
+
+	if len(config) > 0 {
+		cfg := p.Config
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.MaxSize < len(p.Members) {
+			cfg.MaxSize = p.Config.MaxSize
+		}
+		p.Config = cfg
+	}
+	applyMeta(p.Meta, update, remove)
+	p.Revision++
+	p.UpdatedAt = time.Now().UTC()
+
+	snapshot := p.clone()
+	emit(Event{Type: EventPartyUpdated, Party: snapshot, Updated: copyMeta(update), Removed: append([]string{}, remove...)})
+	return snapshot, nil
+}
+
+// UpdateMember applies a member meta patch. revision must match the member's
+// current revision.
+func UpdateMember(partyId, accountId string, revision int, update map[string]string, remove []string) (*Party, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := parties[partyId]
+	if p == nil {
+		return nil, ErrPartyNotFound
+	}
+	m := p.Member(accountId)
+	if m == nil {
+		return nil, ErrMemberNotFound
+	}
+	if revision != m.Revision {
+		return nil, ErrStaleRevision
+	}
+
+	applyMeta(m.Meta, update, remove)
+	m.Revision++
+	m.UpdatedAt = time.Now().UTC()
+	p.UpdatedAt = m.UpdatedAt
+
+	snapshot := p.clone()
+	emit(Event{Type: EventMemberStateUpdated, Party: snapshot, Member: m.clone(), Updated: copyMeta(update), Removed: append([]string{}, remove...)})
+	return snapshot, nil
+}
@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const GiftBoxTemplate = "GiftBox:GB_GiftWrap1"
+
+// NewAthenaItem is a freshly granted cosmetic as the athena profile stores it.
+func NewAthenaItem(templateId string) *Item {
+	return &Item{
+		TemplateID: templateId,
+		Attributes: map[string]interface{}{
+			"favorite":        false,
+			"item_seen":       false,
+			"level":           1,
+			"max_level_bonus": 0,
+			"rnd_sel_cnt":     0,
+			"variants":        []interface{}{},
+			"xp":              0,
+		},
+		Quantity: 1,
+	}
+}
+
+// Gift grants cosmetics in the account's athena profile and records them in
+// a gift box in common_core, which the client shows when it next refreshes
+// the profile. It returns the gift box's item id.
+func Gift(accountId, fromAccountId string, templateIds []string, message string) (string, error) {
+	// The client picks the items up from the full profile on its next
+	// query, so no profile changes are kept here.
+	_, err := Update(accountId, "athena", func(data *ProfileData) error {
+		if data.Items == nil {
+			data.Items = make(map[string]*Item)
+		}
+		for _, templateId := range templateIds {
+			if data.Items[templateId] == nil {
+				data.Items[templateId] = NewAthenaItem(templateId)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	lootList := make([]map[string]interface{}, 0, len(templateIds))
+	for _, templateId := range templateIds {
+		lootList = append(lootList, map[string]interface{}{
+			"itemProfile": "athena",
+			"itemType":    templateId,
+			"itemGuid":    templateId,
+			"quantity":    1,
+		})
+	}
+
+	giftId := uuid.New().String()
+	_, err = Update(accountId, "common_core", func(data *ProfileData) error {
+		if data.Items == nil {
+			data.Items = make(map[string]*Item)
+		}
+		data.Items[giftId] = &Item{
+			TemplateID: GiftBoxTemplate,
+			Attributes: map[string]interface{}{
+				"max_level_bonus": 0,
+				"fromAccountId":   fromAccountId,
+				"lootList":        lootList,
+				"level":           1,
+				"item_seen":       false,
+				"xp":              0,
+				"giftedOn":        time.Now().UTC().Format(time.RFC3339),
+				"params":          map[string]interface{}{"userMessage": message},
+				"favorite":        false,
+			},
+			Quantity: 1,
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return giftId, nil
+}
@@ -0,0 +1,53 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGift(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Join("config", "templates"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"athena", "common_core"} {
+		if err := os.WriteFile(filepath.Join("config", "templates", id+".json"), []byte(`{"items":{}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Concurrent gifts must not lose each other's items.
+	var wg sync.WaitGroup
+	for _, id := range []string{"AthenaCharacter:cid_a", "AthenaCharacter:cid_b", "AthenaPickaxe:pickaxe_c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Gift("alice", "bot", []string{id}, "hi"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	athena, err := ReadProfile("alice", "athena")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(athena.Items) != 3 || athena.Rvn != 3 {
+		t.Errorf("athena has %d items at rvn %d, want 3 at rvn 3", len(athena.Items), athena.Rvn)
+	}
+	core, err := ReadProfile("alice", "common_core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, item := range core.Items {
+		if item.TemplateID != GiftBoxTemplate || item.Attributes["fromAccountId"] != "bot" {
+			t.Errorf("gift box %s = %+v", id, item)
+		}
+	}
+	if len(core.Items) != 3 {
+		t.Errorf("common_core has %d gift boxes, want 3", len(core.Items))
+	}
+}
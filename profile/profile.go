@@ -2,13 +2,19 @@ package profile
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 type ProfileData struct {
 	ID              string           `json:"_id"`
 	AccountID       string           `json:"accountId"`
+	ProfileID       string           `json:"profileId"`
+	Version         string           `json:"version"`
+	WipeNumber      int              `json:"wipeNumber"`
 	Rvn             int              `json:"rvn"`
 	CommandRevision int              `json:"commandRevision"`
 	Created         string           `json:"created"`
@@ -22,7 +28,9 @@ type Stats struct {
 }
 
 type Item struct {
+	TemplateID string                 `json:"templateId"`
 	Attributes map[string]interface{} `json:"attributes"`
+	Quantity   int                    `json:"quantity"`
 }
 
 func ReadProfile(accountId, profileId string) (*ProfileData, error) {
@@ -45,9 +53,13 @@ func SaveProfile(accountId, profileId string, data *ProfileData) error {
 	return os.WriteFile(path, bytes, 0644)
 }
 
+// ReadProfileTemplate reads a new profile, preferring an override in
+// config/templates over the bundled one in profiles.
 func ReadProfileTemplate(profileId string) (*ProfileData, error) {
-	path := filepath.Join("config", "templates", profileId+".json")
-	data, err := os.ReadFile(path)
+	data, err := os.ReadFile(filepath.Join("config", "templates", profileId+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = os.ReadFile(filepath.Join("profiles", "profile_"+profileId+".json"))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +68,62 @@ func ReadProfileTemplate(profileId string) (*ProfileData, error) {
 	return &p, err
 }
 
+// Load reads a profile, creating it from its template on first use.
+func Load(accountId, profileId string) (*ProfileData, error) {
+	data, err := ReadProfile(accountId, profileId)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	data, err = ReadProfileTemplate(profileId)
+	if err != nil {
+		return nil, err
+	}
+	data.Created = time.Now().UTC().Format(time.RFC3339)
+	data.Updated = data.Created
+	data.AccountID = accountId
+	data.ID = accountId
+	data.ProfileID = profileId
+	if data.Items == nil {
+		data.Items = make(map[string]*Item)
+	}
+
+	if err := os.MkdirAll(filepath.Join("config", accountId, "profiles"), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := SaveProfile(accountId, profileId, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+var updateMu sync.Mutex
+
+// Update loads a profile, lets fn change it and saves it with a bumped
+// revision. Updates are serialized so concurrent changes are not lost; fn
+// returning an error leaves the profile untouched.
+func Update(accountId, profileId string, fn func(data *ProfileData) error) (*ProfileData, error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	data, err := Load(accountId, profileId)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(data); err != nil {
+		return nil, err
+	}
+	BumpRvn(data)
+	data.Updated = time.Now().UTC().Format(time.RFC3339)
+	if err := SaveProfile(accountId, profileId, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func BumpRvn(p *ProfileData) {
 	p.Rvn++
 	p.CommandRevision++
@@ -90,3 +158,16 @@ func ChangeItemAttribute(data *ProfileData, itemId, key string, value interface{
 		"value":      value,
 	})
 }
+
+func AddItem(data *ProfileData, itemId string, item *Item, changes *[]interface{}) {
+	if data.Items == nil {
+		data.Items = make(map[string]*Item)
+	}
+	data.Items[itemId] = item
+
+	*changes = append(*changes, map[string]interface{}{
+		"changeType": "itemAdded",
+		"itemId":     itemId,
+		"item":       item,
+	})
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+
+	"neonite-go/structs"
+)
+
+// isAdmin reports whether the request may use admin endpoints: it carries
+// the configured admin key, or no key is configured and it comes from this
+// machine.
+func isAdmin(r *http.Request) bool {
+	if key := structs.Settings.AdminKey; key != "" {
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Key")), []byte(key)) == 1
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireAdmin answers non-admin requests with 403 and returns false.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if isAdmin(r) {
+		return true
+	}
+	structs.SendDetailedError(w, structs.Errors["operation_forbidden"].With("admin key required"), http.StatusForbidden)
+	return false
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
+	"neonite-go/account"
+	"neonite-go/bot"
 	"neonite-go/structs"
 
 	"github.com/gorilla/mux"
@@ -15,6 +18,7 @@ import (
 func RegisterAccountRoutes(r *mux.Router) {
 	r.HandleFunc("/account/api/oauth/token", oauthTokenHandler).Methods("POST")
 	r.HandleFunc("/account/api/oauth/verify", oauthVerifyHandler).Methods("GET")
+	r.HandleFunc("/account/api/oauth/exchange", oauthExchangeHandler).Methods("GET")
 	r.HandleFunc("/account/api/oauth/sessions/kill", killSessionHandler).Methods("DELETE")
 	r.HandleFunc("/account/api/oauth/sessions/kill/{token}", killSessionHandler).Methods("DELETE")
 
@@ -27,6 +31,42 @@ func RegisterAccountRoutes(r *mux.Router) {
 	r.HandleFunc("/account/api/public/account/{accountId}/deviceAuth/{deviceId}", deviceAuthDeleteHandler).Methods("DELETE")
 }
 
+// requireCaller returns the account behind the request's bearer token.
+// Without a valid token it answers invalid_token and reports false.
+func requireCaller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	accountId, ok := account.Caller(r)
+	if !ok {
+		structs.SendDetailedError(w, structs.Errors["invalid_token"], http.StatusUnauthorized)
+	}
+	return accountId, ok
+}
+
+// requireAccount checks that the request is made by one of accountIds,
+// answering invalid_token or operation_forbidden otherwise.
+func requireAccount(w http.ResponseWriter, r *http.Request, accountIds ...string) bool {
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return false
+	}
+	for _, id := range accountIds {
+		if caller == id {
+			return true
+		}
+	}
+	structs.SendDetailedError(w, structs.Errors["operation_forbidden"], http.StatusForbidden)
+	return false
+}
+
+// accountOnly guards a route on {accountId}'s own data so only that account
+// can call it.
+func accountOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requireAccount(w, r, mux.Vars(r)["accountId"]) {
+			h(w, r)
+		}
+	}
+}
+
 func oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
 	type Req struct {
 		GrantType    string
@@ -34,6 +74,7 @@ func oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
 		Code         string
 		AccountID    string
 		ExchangeCode string
+		RefreshToken string
 	}
 
 	var req Req
@@ -54,6 +95,7 @@ func oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
 		req.Code = r.FormValue("code")
 		req.AccountID = r.FormValue("account_id")
 		req.ExchangeCode = r.FormValue("exchange_code")
+		req.RefreshToken = r.FormValue("refresh_token")
 	} else {
 		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("unsupported content type"), http.StatusUnsupportedMediaType)
 		return
@@ -62,9 +104,19 @@ func oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var displayName, accountId string
 
 	switch req.GrantType {
-	case "client_credentials", "refresh_token":
+	case "client_credentials":
 		accountId = "client_user"
 		displayName = "client_user"
+	case "refresh_token":
+		owner, ok := account.RefreshTokenOwner(req.RefreshToken)
+		if !ok {
+			structs.SendDetailedError(w, structs.Errors["invalid_grant"], http.StatusBadRequest)
+			return
+		}
+		accountId, displayName = owner, owner
+		if a := account.Get(owner); a != nil {
+			displayName = a.DisplayName
+		}
 	case "password":
 		if req.Username == "" {
 			structs.SendDetailedError(w, structs.Errors["invalid_request"].With("username"), http.StatusBadRequest)
@@ -93,30 +145,59 @@ func oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		accountId = req.ExchangeCode
 		displayName = req.ExchangeCode
+		// Codes from /account/api/oauth/exchange sign in as the account that
+		// asked for them; any other code is taken as the account id.
+		if owner, ok := account.RedeemExchangeCode(req.ExchangeCode); ok {
+			accountId, displayName = owner, owner
+			if a := account.Get(owner); a != nil {
+				displayName = a.DisplayName
+			}
+		}
 	default:
 		structs.SendDetailedError(w, structs.Errors["unsupported_grant_type"].With(req.GrantType), http.StatusBadRequest)
 		return
 	}
 
+	// Nobody signs in as the lobby bot or its party members.
+	if bot.IsBot(accountId) {
+		structs.SendDetailedError(w, structs.Errors["invalid_grant"], http.StatusBadRequest)
+		return
+	}
+
 	randomBytes := make([]byte, 16)
 	if _, err := rand.Read(randomBytes); err != nil {
 		structs.SendDetailedError(w, structs.Errors["server_error"].With("failed to generate token"), http.StatusInternalServerError)
 		return
 	}
 	accessToken := hex.EncodeToString(randomBytes)
+	now := time.Now().UTC()
+
+	// Client credentials sign in no account, so their tokens are not
+	// stored and pass no caller checks.
+	refreshToken := ""
+	if req.GrantType != "client_credentials" {
+		account.StoreToken(accessToken, accountId, account.AccessTokenLifetime)
+		refreshToken = account.NewToken()
+		account.StoreRefreshToken(refreshToken, accountId, account.RefreshTokenLifetime)
+		if req.GrantType != "refresh_token" {
+			if _, err := account.Record(accountId, displayName); err != nil {
+				structs.NeoLog("Failed to save account " + accountId + ": " + err.Error())
+			}
+		}
+	}
 
 	response := map[string]interface{}{
 		"access_token":       accessToken,
-		"expires_in":         28800,
-		"expires_at":         "9999-12-31T23:59:59.999Z",
+		"expires_in":         int(account.AccessTokenLifetime.Seconds()),
+		"expires_at":         now.Add(account.AccessTokenLifetime).Format(storeTimeFormat),
 		"token_type":         "bearer",
 		"account_id":         accountId,
 		"client_id":          "ec684b8c687f479fadea3cb2ad83f5c6",
 		"internal_client":    true,
 		"client_service":     "fortnite",
-		"refresh_token":      "STATIC_REFRESH_TOKEN",
-		"refresh_expires":    115200,
-		"refresh_expires_at": "9999-12-31T23:59:59.999Z",
+		"refresh_token":      refreshToken,
+		"refresh_expires":    int(account.RefreshTokenLifetime.Seconds()),
+		"refresh_expires_at": now.Add(account.RefreshTokenLifetime).Format(storeTimeFormat),
 		"displayName":        displayName,
 		"app":                "fortnite",
 		"in_app_id":          accountId,
@@ -152,15 +233,46 @@ func oauthVerifyHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// oauthExchangeHandler issues an exchange code for the caller, which newer
+// builds use to sign in to EOS.
+func oauthExchangeHandler(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := account.Caller(r)
+	if !ok {
+		structs.SendDetailedError(w, structs.Errors["invalid_token"], http.StatusUnauthorized)
+		return
+	}
+	code, lifetime := account.NewExchangeCode(accountId)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"expiresInSeconds": int(lifetime.Seconds()),
+		"code":             code,
+		"creatingClientId": "ec684b8c687f479fadea3cb2ad83f5c6",
+	})
+}
+
 func killSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if token := mux.Vars(r)["token"]; token != "" {
+		account.RevokeToken(token)
+	} else if r.URL.Query().Get("killType") == "ALL" {
+		if accountId, ok := account.Caller(r); ok {
+			account.RevokeAccountTokens(accountId)
+		}
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func accountByIDHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["accountId"]
+	displayName := id
+	if bot.IsBot(id) {
+		displayName = bot.DisplayName()
+	} else if a := account.Get(id); a != nil {
+		displayName = a.DisplayName
+	}
 	response := map[string]interface{}{
 		"id":            id,
-		"displayName":   id,
+		"displayName":   displayName,
 		"externalAuths": map[string]interface{}{},
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -169,8 +281,12 @@ func accountByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 func accountByDisplayNameHandler(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["displayName"]
+	id := name
+	if a := account.FindByDisplayName(name); a != nil {
+		id, name = a.ID, a.DisplayName
+	}
 	response := map[string]interface{}{
-		"id":            name,
+		"id":            id,
 		"displayName":   name,
 		"externalAuths": map[string]interface{}{},
 	}
@@ -188,8 +304,10 @@ func accountBatchHandler(w http.ResponseWriter, r *http.Request) {
 	var response []map[string]interface{}
 	for _, id := range ids {
 		displayName := id
-		if strings.HasPrefix(id, "NeoniteBot") {
-			displayName = "NeoniteBot"
+		if bot.IsBot(id) {
+			displayName = bot.DisplayName()
+		} else if a := account.Get(id); a != nil {
+			displayName = a.DisplayName
 		}
 		response = append(response, map[string]interface{}{
 			"id":            id,
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"neonite-go/account"
+)
+
+func requestToken(r http.Handler, form url.Values) (int, map[string]interface{}) {
+	req := httptest.NewRequest("POST", "/account/api/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+func TestRefreshTokenGrant(t *testing.T) {
+	r := testRouter(RegisterAccountRoutes)
+
+	code, body := requestToken(r, url.Values{"grant_type": {"password"}, "username": {"refresher@neonite.dev"}})
+	if code != http.StatusOK {
+		t.Fatalf("password grant: %d %v", code, body)
+	}
+	refresh, _ := body["refresh_token"].(string)
+	if refresh == "" {
+		t.Fatal("password grant returned no refresh token")
+	}
+
+	code, body = requestToken(r, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}})
+	if code != http.StatusOK || body["account_id"] != "refresher" {
+		t.Fatalf("refresh grant = %d %v, want the refresher account", code, body)
+	}
+	if owner, ok := account.TokenOwner(body["access_token"].(string)); !ok || owner != "refresher" {
+		t.Errorf("refreshed token owner = %q, %v", owner, ok)
+	}
+
+	code, _ = requestToken(r, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"STATIC_REFRESH_TOKEN"}})
+	if code != http.StatusBadRequest {
+		t.Errorf("unknown refresh token = %d, want 400", code)
+	}
+
+	code, body = requestToken(r, url.Values{"grant_type": {"client_credentials"}})
+	if code != http.StatusOK {
+		t.Fatalf("client credentials: %d", code)
+	}
+	if _, ok := account.TokenOwner(body["access_token"].(string)); ok {
+		t.Error("client credentials token passes as an account")
+	}
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"neonite-go/cloudstorage"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterCloudStorageRoutes(r *mux.Router) {
+	r.HandleFunc("/fortnite/api/cloudstorage/system", SystemFilesHandler).Methods("GET")
+	r.HandleFunc("/fortnite/api/cloudstorage/system/config", CloudStorageConfigHandler).Methods("GET")
+	r.HandleFunc("/fortnite/api/cloudstorage/system/{filename}", SystemFileHandler).Methods("GET")
+
+	r.HandleFunc("/api/v1/admin/hotfixes", HotfixTemplatesHandler).Methods("GET")
+	r.HandleFunc("/api/v1/admin/hotfixes/{filename}", HotfixPreviewHandler).Methods("GET")
+
+	r.HandleFunc("/fortnite/api/cloudstorage/user/{accountId}", accountOnly(UserFilesHandler)).Methods("GET")
+	r.HandleFunc("/fortnite/api/cloudstorage/user/{accountId}/{filename}", accountOnly(UserFileHandler)).Methods("GET")
+	r.HandleFunc("/fortnite/api/cloudstorage/user/{accountId}/{filename}", accountOnly(PutUserFileHandler)).Methods("PUT")
+	r.HandleFunc("/fortnite/api/cloudstorage/user/{accountId}/{filename}", accountOnly(DeleteUserFileHandler)).Methods("DELETE")
+}
+
+// SystemFilesHandler lists the hotfix files for the requesting build.
+func SystemFilesHandler(w http.ResponseWriter, r *http.Request) {
+	files := cloudstorage.SystemFiles(structs.GetBuildInfo(r))
+	list := make([]cloudstorage.Info, len(files))
+	for i, f := range files {
+		list[i] = f.Info()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// SystemFileHandler downloads one hotfix file.
+func SystemFileHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["filename"]
+	f, err := cloudstorage.SystemFile(structs.GetBuildInfo(r), name)
+	if err != nil {
+		structs.SendDetailedError(w, structs.Errors["file_not_found"], http.StatusNotFound)
+		return
+	}
+	sendCloudFile(w, f)
+}
+
+func sendCloudFile(w http.ResponseWriter, f cloudstorage.File) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Last-Modified", f.Uploaded.UTC().Format(http.TimeFormat))
+	w.Write(f.Data)
+}
+
+// CloudStorageConfigHandler tells newer clients to list files through the
+// v1 endpoints above.
+func CloudStorageConfigHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"lastUpdated":        time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		"disableV2":          true,
+		"isAuthenticated":    true,
+		"enumerateFilesPath": "/api/cloudstorage/system",
+		"enableMigration":    false,
+		"enableWrites":       false,
+		"epicAppName":        "Live",
+		"transports":         map[string]interface{}{},
+	})
+}
+
+// HotfixTemplatesHandler lists the hotfix templates.
+func HotfixTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cloudstorage.HotfixTemplates())
+}
+
+// HotfixPreviewHandler shows a hotfix template rendered for the build in the
+// version query parameter, e.g. ?version=14.60, or for the caller's build
+// when it is left out.
+func HotfixPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	b := structs.GetBuildInfo(r)
+	if v := r.URL.Query().Get("version"); v != "" {
+		var ok bool
+		if b, ok = structs.ParseVersion(v); !ok {
+			structs.SendDetailedError(w, structs.Errors["invalid_request"].With("version"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, err := cloudstorage.RenderHotfix(b, mux.Vars(r)["filename"])
+	switch {
+	case errors.Is(err, cloudstorage.ErrNotFound):
+		structs.SendDetailedError(w, structs.Errors["file_not_found"], http.StatusNotFound)
+		return
+	case err != nil:
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With(err.Error()), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(f.Data)
+}
+
+func sendCloudStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cloudstorage.ErrNotFound):
+		structs.SendDetailedError(w, structs.Errors["file_not_found"], http.StatusNotFound)
+	case errors.Is(err, cloudstorage.ErrInvalidName):
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With(err.Error()), http.StatusBadRequest)
+	case errors.Is(err, cloudstorage.ErrTooLarge):
+		structs.SendDetailedError(w, structs.Errors["file_too_large"], http.StatusRequestEntityTooLarge)
+	default:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
+	}
+}
+
+// UserFilesHandler lists an account's saved files, such as
+// ClientSettings.Sav, for the requesting build's branch.
+func UserFilesHandler(w http.ResponseWriter, r *http.Request) {
+	files, err := cloudstorage.UserFiles(mux.Vars(r)["accountId"], structs.GetBuildInfo(r).Branch())
+	if err != nil {
+		sendCloudStorageError(w, err)
+		return
+	}
+	list := make([]cloudstorage.Info, len(files))
+	for i, f := range files {
+		list[i] = f.Info()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func UserFileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	f, err := cloudstorage.UserFile(vars["accountId"], structs.GetBuildInfo(r).Branch(), vars["filename"])
+	if err != nil {
+		sendCloudStorageError(w, err)
+		return
+	}
+	sendCloudFile(w, f)
+}
+
+// PutUserFileHandler saves the raw request body as one of the account's
+// files.
+func PutUserFileHandler(w http.ResponseWriter, r *http.Request) {
+	// Read one byte past the limit so oversized files are refused rather
+	// than cut short.
+	data, err := io.ReadAll(io.LimitReader(r.Body, structs.Settings.CloudStorageMaxSize+1))
+	if err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With(err.Error()), http.StatusBadRequest)
+		return
+	}
+	vars := mux.Vars(r)
+	if _, err := cloudstorage.PutUserFile(vars["accountId"], structs.GetBuildInfo(r).Branch(), vars["filename"], data); err != nil {
+		sendCloudStorageError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func DeleteUserFileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := cloudstorage.DeleteUserFile(vars["accountId"], structs.GetBuildInfo(r).Branch(), vars["filename"]); err != nil {
+		sendCloudStorageError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
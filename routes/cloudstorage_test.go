@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUserFilesRequireOwner(t *testing.T) {
+	r := testRouter(RegisterCloudStorageRoutes)
+	owner := login("cloud-owner")
+	other := login("cloud-other")
+	base := "/fortnite/api/cloudstorage/user/cloud-owner"
+
+	tests := []struct {
+		name, method, path, token, body string
+		want                            int
+	}{
+		{"list without token", "GET", base, "", "", http.StatusUnauthorized},
+		{"get without token", "GET", base + "/ClientSettings.Sav", "", "", http.StatusUnauthorized},
+		{"put without token", "PUT", base + "/ClientSettings.Sav", "", "data", http.StatusUnauthorized},
+		{"delete without token", "DELETE", base + "/ClientSettings.Sav", "", "", http.StatusUnauthorized},
+		{"list by someone else", "GET", base, other, "", http.StatusForbidden},
+		{"put by someone else", "PUT", base + "/ClientSettings.Sav", other, "data", http.StatusForbidden},
+		{"put by owner", "PUT", base + "/ClientSettings.Sav", owner, "data", http.StatusNoContent},
+		{"get by owner", "GET", base + "/ClientSettings.Sav", owner, "", http.StatusOK},
+		{"get by someone else", "GET", base + "/ClientSettings.Sav", other, "", http.StatusForbidden},
+		{"delete by owner", "DELETE", base + "/ClientSettings.Sav", owner, "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if w := call(r, tt.method, tt.path, tt.token, tt.body); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
@@ -1 +1,347 @@
 package routes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"neonite-go/account"
+	"neonite-go/friends"
+	"neonite-go/routes/xmpp"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+// Fortnite's EOS ids. Clients check the ones they get back against their
+// own, so they cannot be made up.
+const (
+	eosClientID      = "ec684b8c687f479fadea3cb2ad83f5c6"
+	eosApplicationID = "fghi4567FNFBKFz3E4TROb0bmPS8h1GW"
+	eosDeploymentID  = "62a9473a2dca46b29ccf17577fcf42d7"
+	eosOrganization  = "o-aa83a0a9bc45e98c80c1b1c9d92e9e"
+	eosProductID     = "prod-fn"
+	eosSandboxID     = "fn"
+
+	eosTokenLifetime   = 2 * time.Hour
+	eosRefreshLifetime = 8 * time.Hour
+)
+
+func RegisterEOSRoutes(r *mux.Router) {
+	r.HandleFunc("/epic/oauth/v2/token", EOSTokenHandler).Methods("POST")
+	r.HandleFunc("/epic/oauth/v2/tokenInfo", EOSTokenInfoHandler).Methods("POST")
+	r.HandleFunc("/epic/id/v2/sso", EOSSSOHandler).Methods("GET")
+	r.HandleFunc("/auth/v1/oauth/token", EOSConnectTokenHandler).Methods("POST")
+
+	friendsBase := "/epic/friends/v1/{accountId}"
+	r.HandleFunc(friendsBase, accountOnly(EOSFriendsSummaryHandler)).Methods("GET")
+	r.HandleFunc(friendsBase+"/friends", accountOnly(EOSFriendsListHandler)).Methods("GET")
+	r.HandleFunc(friendsBase+"/incoming", accountOnly(EOSIncomingFriendsHandler)).Methods("GET")
+	r.HandleFunc(friendsBase+"/outgoing", accountOnly(EOSOutgoingFriendsHandler)).Methods("GET")
+	r.HandleFunc(friendsBase+"/blocklist", accountOnly(EOSBlocklistHandler)).Methods("GET")
+
+	presenceBase := "/epic/presence/v1/{namespace}/{accountId}"
+	r.HandleFunc(presenceBase+"/presence/{presenceId}", accountOnly(EOSSetPresenceHandler)).Methods("PATCH")
+	r.HandleFunc(presenceBase+"/last-online", accountOnly(EOSLastOnlineHandler)).Methods("GET")
+	r.HandleFunc(presenceBase+"/subscriptions", EmptyListHandler).Methods("GET")
+}
+
+// idTokenKey signs the id tokens. Nothing verifies them but this server, so
+// a key per run is enough.
+var idTokenKey = func() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+// idToken is the OpenID token EOS hands out with an access token.
+func idToken(accountId, displayName, clientId string, issued time.Time) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"sub":                accountId,
+		"aud":                clientId,
+		"iss":                "https://api.epicgames.dev/epic/oauth/v1",
+		"preferred_username": displayName,
+		"appid":              eosApplicationID,
+		"iat":                issued.Unix(),
+		"exp":                issued.Add(eosTokenLifetime).Unix(),
+	})
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	mac := hmac.New(sha256.New, idTokenKey)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func eosClient(r *http.Request) string {
+	if clientId, _, ok := r.BasicAuth(); ok && clientId != "" {
+		return clientId
+	}
+	return eosClientID
+}
+
+// EOSTokenHandler is the EOS login. It accepts the exchange code the game
+// gets from /account/api/oauth/exchange, a refresh token it issued, or a
+// Fortnite access token, and signs in as the same account. Anything else
+// is an invalid grant.
+func EOSTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid form data"), http.StatusBadRequest)
+		return
+	}
+
+	var accountId string
+	var ok bool
+	switch grant := r.FormValue("grant_type"); grant {
+	case "exchange_code":
+		code := r.FormValue("exchange_code")
+		if code == "" {
+			structs.SendDetailedError(w, structs.Errors["invalid_request"].With("exchange_code"), http.StatusBadRequest)
+			return
+		}
+		accountId, ok = account.RedeemExchangeCode(code)
+	case "refresh_token":
+		accountId, ok = account.RefreshTokenOwner(r.FormValue("refresh_token"))
+	case "token_to_token":
+		accountId, ok = account.TokenOwner(r.FormValue("access_token"))
+	case "client_credentials":
+		ok = true
+	default:
+		structs.SendDetailedError(w, structs.Errors["unsupported_grant_type"].With(grant), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		structs.SendDetailedError(w, structs.Errors["invalid_grant"], http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	clientId := eosClient(r)
+	accessToken := account.NewToken()
+	response := map[string]interface{}{
+		"scope":          "basic_profile friends_list openid presence",
+		"token_type":     "bearer",
+		"access_token":   accessToken,
+		"expires_in":     int(eosTokenLifetime.Seconds()),
+		"expires_at":     now.Add(eosTokenLifetime).Format(storeTimeFormat),
+		"client_id":      clientId,
+		"application_id": eosApplicationID,
+	}
+	if accountId != "" {
+		account.StoreToken(accessToken, accountId, eosTokenLifetime)
+		refreshToken := account.NewToken()
+		account.StoreRefreshToken(refreshToken, accountId, eosRefreshLifetime)
+		if _, err := account.Record(accountId, displayNameOf(accountId, nil)); err != nil {
+			structs.NeoLog("Failed to save account " + accountId + ": " + err.Error())
+		}
+
+		response["refresh_token"] = refreshToken
+		response["refresh_expires_in"] = int(eosRefreshLifetime.Seconds())
+		response["refresh_expires_at"] = now.Add(eosRefreshLifetime).Format(storeTimeFormat)
+		response["account_id"] = accountId
+		response["selected_account_id"] = accountId
+		response["merged_accounts"] = []interface{}{}
+		response["acr"] = "urn:epic:loa:aal1"
+		response["auth_time"] = now.Format(storeTimeFormat)
+		response["id_token"] = idToken(accountId, displayNameOf(accountId, nil), clientId, now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EOSTokenInfoHandler reports whether an EOS access token is still valid.
+func EOSTokenInfoHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	token := r.FormValue("token")
+	accountId, ok := account.TokenOwner(token)
+
+	response := map[string]interface{}{"active": ok}
+	if ok {
+		response["scope"] = "basic_profile friends_list openid presence"
+		response["token_type"] = "bearer"
+		response["client_id"] = eosClient(r)
+		response["application_id"] = eosApplicationID
+		response["account_id"] = accountId
+		response["expires_in"] = int(eosTokenLifetime.Seconds())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EOSSSOHandler lists the domains a web sign in is shared with.
+func EOSSSOHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sso": []string{"epicgames.com", "fortnite.com", "unrealengine.com"},
+	})
+}
+
+// EOSConnectTokenHandler issues EOS Connect tokens. With an external_auth
+// grant holding an Epic access token, the product user is that token's
+// account.
+func EOSConnectTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid form data"), http.StatusBadRequest)
+		return
+	}
+
+	var accountId string
+	switch grant := r.FormValue("grant_type"); grant {
+	case "client_credentials":
+	case "external_auth":
+		var ok bool
+		if accountId, ok = account.TokenOwner(r.FormValue("external_auth_token")); !ok {
+			structs.SendDetailedError(w, structs.Errors["invalid_grant"], http.StatusBadRequest)
+			return
+		}
+	default:
+		structs.SendDetailedError(w, structs.Errors["unsupported_grant_type"].With(grant), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	accessToken := account.NewToken()
+	deploymentId := r.FormValue("deployment_id")
+	if deploymentId == "" {
+		deploymentId = eosDeploymentID
+	}
+	response := map[string]interface{}{
+		"access_token":    accessToken,
+		"token_type":      "bearer",
+		"expires_in":      int(eosTokenLifetime.Seconds()) - 1,
+		"expires_at":      now.Add(eosTokenLifetime).Format(storeTimeFormat),
+		"nonce":           r.FormValue("nonce"),
+		"features":        []string{"AntiCheat", "Connect", "ContentService", "Ecom", "Inventories", "LockerService", "Matchmaking Service"},
+		"organization_id": eosOrganization,
+		"product_id":      eosProductID,
+		"sandbox_id":      eosSandboxID,
+		"deployment_id":   deploymentId,
+		"client_id":       eosClient(r),
+	}
+	if accountId != "" {
+		account.StoreToken(accessToken, accountId, eosTokenLifetime)
+		response["product_user_id"] = accountId
+		response["account_id"] = accountId
+		response["organization_user_id"] = accountId
+		response["product_user_id_created"] = false
+		response["id_token"] = idToken(accountId, displayNameOf(accountId, nil), eosClient(r), now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// eosFriendJSON is a friend entry as the EOS friends service returns it.
+func eosFriendJSON(f friends.Friend) map[string]interface{} {
+	return map[string]interface{}{
+		"accountId": f.AccountID,
+		"status":    f.Status,
+		"created":   f.Created,
+	}
+}
+
+// EOSFriendsSummaryHandler returns an account's friends, pending requests
+// both ways and blocklist.
+func EOSFriendsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"friends":   mapFriends(list.Filter(friends.StatusAccepted, ""), eosFriendJSON),
+		"incoming":  mapFriends(list.Filter(friends.StatusPending, friends.DirectionInbound), eosFriendJSON),
+		"outgoing":  mapFriends(list.Filter(friends.StatusPending, friends.DirectionOutbound), eosFriendJSON),
+		"blocklist": blocklistJSON(list.Blocked),
+		"settings":  map[string]interface{}{"acceptInvites": "public"},
+	})
+}
+
+func EOSFriendsListHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapFriends(list.Filter(friends.StatusAccepted, ""), eosFriendJSON))
+}
+
+func EOSIncomingFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapFriends(list.Filter(friends.StatusPending, friends.DirectionInbound), eosFriendJSON))
+}
+
+func EOSOutgoingFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapFriends(list.Filter(friends.StatusPending, friends.DirectionOutbound), eosFriendJSON))
+}
+
+func EOSBlocklistHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocklistJSON(list.Blocked))
+}
+
+// EOSSetPresenceHandler publishes the presence newer builds send through
+// EOS like an XMPP presence, so friends and the presence endpoints see it.
+func EOSSetPresenceHandler(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+
+	var body struct {
+		Status   string `json:"status"`
+		Activity struct {
+			Value string `json:"value"`
+		} `json:"activity"`
+		Props map[string]interface{} `json:"props"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if body.Status == "" {
+		body.Status = "online"
+	}
+	if body.Props == nil {
+		body.Props = map[string]interface{}{}
+	}
+
+	show := ""
+	if body.Status == "away" || body.Status == "dnd" || body.Status == "xa" {
+		show = body.Status
+	}
+	status, _ := json.Marshal(map[string]interface{}{"Status": body.Activity.Value, "Properties": body.Props})
+	rec := xmpp.SetPresence(accountId, string(status), show, body.Status != "offline")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"own": map[string]interface{}{
+			"accountId":  accountId,
+			"status":     body.Status,
+			"perNs":      []interface{}{},
+			"isVisible":  true,
+			"activity":   map[string]interface{}{"value": body.Activity.Value},
+			"props":      body.Props,
+			"lastOnline": rec.LastOnline.Format(storeTimeFormat),
+			"conns":      []interface{}{},
+		},
+	})
+}
+
+// EOSLastOnlineHandler tells an account when each of its friends was last
+// online.
+func EOSLastOnlineHandler(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+
+	response := map[string]interface{}{}
+	for _, f := range friends.Get(accountId).Filter(friends.StatusAccepted, "") {
+		if rec, ok := xmpp.LastPresence(f.AccountID); ok {
+			response[f.AccountID] = []map[string]interface{}{
+				{"last_online": rec.LastOnline.Format(storeTimeFormat)},
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"neonite-go/account"
+	"neonite-go/friends"
+	"neonite-go/routes/xmpp"
+)
+
+func TestEOSExchangeCode(t *testing.T) {
+	r := testRouter(RegisterEOSRoutes)
+	code, _ := account.NewExchangeCode("eos-user")
+
+	tests := []struct {
+		name, code string
+		want       int
+	}{
+		{"issued code", code, http.StatusOK},
+		{"code used twice", code, http.StatusBadRequest},
+		{"unknown code", "eos-user", http.StatusBadRequest},
+		{"empty code", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		form := url.Values{"grant_type": {"exchange_code"}, "exchange_code": {tt.code}}
+		req := httptest.NewRequest("POST", "/epic/oauth/v2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestEOSPresenceRequiresOwner(t *testing.T) {
+	r := testRouter(RegisterEOSRoutes)
+	owner := login("eos-owner")
+	other := login("eos-other")
+	base := "/epic/presence/v1/Fortnite/eos-owner"
+	body := `{"status":"online","activity":{"value":"In Lobby"}}`
+
+	tests := []struct {
+		name, method, path, token, body string
+		want                            int
+	}{
+		{"set without token", "PATCH", base + "/presence/x", "", body, http.StatusUnauthorized},
+		{"set for someone else", "PATCH", base + "/presence/x", other, body, http.StatusForbidden},
+		{"set by owner", "PATCH", base + "/presence/x", owner, body, http.StatusOK},
+		{"last online without token", "GET", base + "/last-online", "", "", http.StatusUnauthorized},
+		{"last online of someone else", "GET", base + "/last-online", other, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := call(r, tt.method, tt.path, tt.token, tt.body); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestEOSPresenceReachesFriends(t *testing.T) {
+	r := testRouter(RegisterEOSRoutes)
+	friends.Add("eos-alice", "eos-bob")
+	friends.Add("eos-bob", "eos-alice")
+	bob := &recordingConn{accountId: "eos-bob"}
+	xmpp.Register(bob)
+	defer xmpp.Unregister(bob)
+
+	body := `{"status":"online","activity":{"value":"In Lobby"}}`
+	w := call(r, "PATCH", "/epic/presence/v1/Fortnite/eos-alice/presence/x", login("eos-alice"), body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set presence: %d %s", w.Code, w.Body)
+	}
+
+	bob.mu.Lock()
+	defer bob.mu.Unlock()
+	if len(bob.stanzas) != 1 || !strings.Contains(bob.stanzas[0], `from="eos-alice@`) || !strings.Contains(bob.stanzas[0], "In Lobby") {
+		t.Errorf("bob got %v, want alice's presence", bob.stanzas)
+	}
+}
+
+func TestEOSFriends(t *testing.T) {
+	r := testRouter(RegisterEOSRoutes)
+	friends.Add("eos-carol", "eos-dave")
+	friends.Add("eos-dave", "eos-carol")
+	friends.Add("eos-erin", "eos-carol")
+	carol := login("eos-carol")
+	base := "/epic/friends/v1/eos-carol"
+
+	for _, path := range []string{base, base + "/friends", base + "/incoming", base + "/outgoing", base + "/blocklist"} {
+		if w := call(r, "GET", path, "", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without token: status %d, want 401", path, w.Code)
+		}
+		if w := call(r, "GET", path, login("eos-dave"), ""); w.Code != http.StatusForbidden {
+			t.Errorf("%s of someone else: status %d, want 403", path, w.Code)
+		}
+	}
+
+	w := call(r, "GET", base, carol, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("summary: %d %s", w.Code, w.Body)
+	}
+	var summary map[string][]map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &summary)
+	if len(summary["friends"]) != 1 || summary["friends"][0]["accountId"] != "eos-dave" || summary["friends"][0]["status"] != "ACCEPTED" {
+		t.Errorf("friends = %v, want eos-dave accepted", summary["friends"])
+	}
+	if len(summary["incoming"]) != 1 || summary["incoming"][0]["accountId"] != "eos-erin" {
+		t.Errorf("incoming = %v, want eos-erin", summary["incoming"])
+	}
+}
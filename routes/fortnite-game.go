@@ -1 +1,276 @@
 package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"neonite-go/content"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterContentRoutes(r *mux.Router) {
+	r.HandleFunc("/content/api/pages/fortnite-game", FortniteGameHandler).Methods("GET")
+
+	r.HandleFunc("/api/v1/admin/news", NewsListHandler).Methods("GET")
+	r.HandleFunc("/api/v1/admin/news", PostNewsHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/news/{id}", ExpireNewsHandler).Methods("DELETE")
+}
+
+// page wraps a content section in the fields every section carries.
+func page(title, locale, modified string, fields map[string]interface{}) map[string]interface{} {
+	fields["_title"] = title
+	fields["_noIndex"] = false
+	fields["_activeDate"] = "2017-08-30T03:20:48.050Z"
+	fields["lastModified"] = modified
+	fields["_locale"] = locale
+	return fields
+}
+
+// FortniteGameHandler serves the lobby content pages for the client's build.
+func FortniteGameHandler(w http.ResponseWriter, r *http.Request) {
+	b := structs.GetBuildInfo(r)
+	langs := content.Languages(r.Header.Get("Accept-Language"))
+	locale := "en-US"
+	if len(langs) > 0 {
+		locale = langs[0]
+	}
+	modified := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	pages := content.Get()
+
+	notices := []map[string]interface{}{}
+	for _, n := range pages.EmergencyNotices {
+		if !n.AppliesTo(b) {
+			continue
+		}
+		notices = append(notices, map[string]interface{}{
+			"hidden": n.Hidden,
+			"title":  n.Title.In(langs),
+			"body":   n.Body.In(langs),
+		})
+	}
+	noticeMessages := make([]map[string]interface{}, len(notices))
+	noticesV2 := make([]map[string]interface{}, len(notices))
+	for i, n := range notices {
+		noticeMessages[i] = map[string]interface{}{
+			"_type":     "CommonUI Simple Message Base",
+			"subgame":   "br",
+			"spotlight": false,
+			"hidden":    n["hidden"],
+			"title":     n["title"],
+			"body":      n["body"],
+		}
+		noticesV2[i] = map[string]interface{}{
+			"_type":  "CommonUI Emergency Notice Base",
+			"hidden": n["hidden"],
+			"title":  n["title"],
+			"body":   n["body"],
+		}
+	}
+
+	// Old builds show the news as messages; newer ones as MOTD tiles.
+	motds := []map[string]interface{}{}
+	messages := []map[string]interface{}{}
+	for _, n := range content.ActiveNews(b) {
+		title, body := n.Title.In(langs), n.Body.In(langs)
+		tileImage := n.TileImage
+		if tileImage == "" {
+			tileImage = n.Image
+		}
+		motds = append(motds, map[string]interface{}{
+			"_type":                 "CommonUI Simple Message MOTD",
+			"entryType":             "Text",
+			"id":                    n.ID,
+			"title":                 title,
+			"tabTitleOverride":      title,
+			"body":                  body,
+			"image":                 n.Image,
+			"tileImage":             tileImage,
+			"hidden":                false,
+			"spotlight":             false,
+			"sortingPriority":       n.SortingPriority,
+			"videoMute":             false,
+			"videoLoop":             false,
+			"videoAutoplay":         false,
+			"videoFullscreen":       false,
+			"videoStreamingEnabled": false,
+		})
+		messages = append(messages, map[string]interface{}{
+			"_type":       "CommonUI Simple Message Base",
+			"adspace":     "",
+			"messagetype": "normal",
+			"title":       title,
+			"body":        body,
+			"image":       n.Image,
+			"hidden":      false,
+			"spotlight":   false,
+		})
+	}
+
+	playlists := []map[string]interface{}{}
+	for _, p := range pages.Playlists {
+		if !p.AppliesTo(b) {
+			continue
+		}
+		playlists = append(playlists, map[string]interface{}{
+			"_type":          "FortPlaylistInfo",
+			"playlist_name":  p.Name,
+			"image":          p.Image,
+			"display_name":   p.DisplayName.In(langs),
+			"description":    p.Description.In(langs),
+			"violator":       p.Violator.In(langs),
+			"hidden":         false,
+			"special_border": "None",
+		})
+	}
+
+	tournaments := []map[string]interface{}{}
+	for _, t := range pages.Tournaments {
+		if !t.AppliesTo(b) {
+			continue
+		}
+		entry := map[string]interface{}{}
+		for k, v := range t.Extra {
+			entry[k] = v
+		}
+		for k, v := range map[string]interface{}{
+			"_type":                 "Tournament Display Info",
+			"tournament_display_id": t.DisplayID,
+			"title_line_1":          t.TitleLine1.In(langs),
+			"title_line_2":          t.TitleLine2.In(langs),
+			"schedule_info":         t.ScheduleInfo.In(langs),
+			"flavor_description":    t.FlavorDescription.In(langs),
+			"details_description":   t.DetailsDescription.In(langs),
+			"short_format_title":    t.ShortFormatTitle.In(langs),
+			"long_format_title":     t.LongFormatTitle.In(langs),
+			"poster_front_image":    t.PosterFrontImage,
+			"poster_back_image":     t.PosterBackImage,
+			"loading_screen_image":  t.LoadingScreenImage,
+			"playlist_tile_image":   t.PlaylistTileImage,
+		} {
+			entry[k] = v
+		}
+		tournaments = append(tournaments, entry)
+	}
+
+	subgames := page("subgameselectdata", locale, modified, map[string]interface{}{})
+	for name, s := range pages.Subgames {
+		subgames[name] = map[string]interface{}{
+			"_type": "CommonUI Simple Message",
+			"message": map[string]interface{}{
+				"_type":       "CommonUI Simple Message Base",
+				"messagetype": "normal",
+				"title":       s.Title.In(langs),
+				"body":        s.Body.In(langs),
+				"image":       s.Image,
+				"hidden":      false,
+				"spotlight":   false,
+			},
+		}
+	}
+
+	resp := page("Fortnite Game", locale, modified, map[string]interface{}{
+		"emergencynotice": page("emergencynotice", locale, modified, map[string]interface{}{
+			"news": map[string]interface{}{
+				"_type":             "Battle Royale News",
+				"platform_messages": []interface{}{},
+				"messages":          noticeMessages,
+			},
+		}),
+		"emergencynoticev2": page("emergencynoticev2", locale, modified, map[string]interface{}{
+			"emergencynotices": map[string]interface{}{
+				"_type":            "Emergency Notices",
+				"emergencynotices": noticesV2,
+			},
+		}),
+		"battleroyalenews": page("battleroyalenews", locale, modified, map[string]interface{}{
+			"header":     "",
+			"style":      "None",
+			"alwaysShow": false,
+			"news": map[string]interface{}{
+				"_type":             "Battle Royale News",
+				"platform_messages": []interface{}{},
+				"motds":             motds,
+				"messages":          messages,
+			},
+		}),
+		"battleroyalenewsv2": page("battleroyalenewsv2", locale, modified, map[string]interface{}{
+			"news": map[string]interface{}{
+				"_type": "Battle Royale News v2",
+				"motds": motds,
+			},
+		}),
+		"playlistinformation": page("playlistinformation", locale, modified, map[string]interface{}{
+			"frontend_matchmaking_header_style": "None",
+			"frontend_matchmaking_header_text":  "",
+			"playlist_info": map[string]interface{}{
+				"_type":     "Playlist Information",
+				"playlists": playlists,
+			},
+		}),
+		"tournamentinformation": page("tournamentinformation", locale, modified, map[string]interface{}{
+			"tournament_info": map[string]interface{}{
+				"_type":       "Tournaments Info",
+				"tournaments": tournaments,
+			},
+		}),
+		"subgameselectdata": subgames,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// NewsListHandler lists the posted news, expired entries included.
+func NewsListHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(content.Posted())
+}
+
+// PostNewsHandler posts or replaces a news entry.
+func PostNewsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var n content.News
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	n, err := content.PostNews(n)
+	switch {
+	case errors.Is(err, content.ErrInvalidNews):
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("title, body or build range"), http.StatusBadRequest)
+		return
+	case err != nil:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
+
+// ExpireNewsHandler takes a posted news entry down.
+func ExpireNewsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	n, err := content.ExpireNews(mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, content.ErrNewsNotFound):
+		structs.SendDetailedError(w, structs.Errors["not_found"], http.StatusNotFound)
+		return
+	case err != nil:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n)
+}
@@ -0,0 +1,416 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"neonite-go/account"
+	"neonite-go/bot"
+	"neonite-go/friends"
+	"neonite-go/routes/xmpp"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterFriendsRoutes(r *mux.Router) {
+	base := "/friends/api/v1/{accountId}"
+	r.HandleFunc(base+"/summary", accountOnly(FriendsSummaryHandler)).Methods("GET")
+	r.HandleFunc(base+"/friends", accountOnly(FriendsListHandler)).Methods("GET")
+	r.HandleFunc(base+"/incoming", accountOnly(IncomingFriendsHandler)).Methods("GET")
+	r.HandleFunc(base+"/outgoing", accountOnly(OutgoingFriendsHandler)).Methods("GET")
+	r.HandleFunc(base+"/blocklist", accountOnly(BlocklistHandler)).Methods("GET")
+	r.HandleFunc(base+"/settings", accountOnly(FriendSettingsHandler)).Methods("GET")
+	r.HandleFunc(base+"/recent/{namespace}", EmptyListHandler).Methods("GET")
+
+	r.HandleFunc(base+"/friends/{friendId}", accountOnly(AddFriendHandler)).Methods("POST")
+	r.HandleFunc(base+"/friends/{friendId}", accountOnly(RemoveFriendHandler)).Methods("DELETE")
+	r.HandleFunc(base+"/incoming/{friendId}", accountOnly(RemoveFriendHandler)).Methods("DELETE")
+	r.HandleFunc(base+"/outgoing/{friendId}", accountOnly(RemoveFriendHandler)).Methods("DELETE")
+	r.HandleFunc(base+"/blocklist/{friendId}", accountOnly(BlockFriendHandler)).Methods("POST")
+	r.HandleFunc(base+"/blocklist/{friendId}", accountOnly(UnblockFriendHandler)).Methods("DELETE")
+	r.HandleFunc(base+"/friends/{friendId}/alias", accountOnly(SetFriendAliasHandler)).Methods("PUT")
+	r.HandleFunc(base+"/friends/{friendId}/alias", accountOnly(ClearFriendAliasHandler)).Methods("DELETE")
+	r.HandleFunc(base+"/friends/{friendId}/note", accountOnly(SetFriendNoteHandler)).Methods("PUT")
+	r.HandleFunc(base+"/friends/{friendId}/note", accountOnly(ClearFriendNoteHandler)).Methods("DELETE")
+
+	legacy := "/friends/api/public"
+	r.HandleFunc(legacy+"/friends/{accountId}", accountOnly(LegacyFriendsListHandler)).Methods("GET")
+	r.HandleFunc(legacy+"/friends/{accountId}/{friendId}", accountOnly(AddFriendHandler)).Methods("POST")
+	r.HandleFunc(legacy+"/friends/{accountId}/{friendId}", accountOnly(RemoveFriendHandler)).Methods("DELETE")
+	r.HandleFunc(legacy+"/blocklist/{accountId}", accountOnly(LegacyBlocklistHandler)).Methods("GET")
+	r.HandleFunc(legacy+"/blocklist/{accountId}/{friendId}", accountOnly(BlockFriendHandler)).Methods("POST")
+	r.HandleFunc(legacy+"/blocklist/{accountId}/{friendId}", accountOnly(UnblockFriendHandler)).Methods("DELETE")
+	r.HandleFunc(legacy+"/list/fortnite/{accountId}/recentPlayers", EmptyListHandler).Methods("GET")
+}
+
+func acceptedFriendJSON(f friends.Friend) map[string]interface{} {
+	return map[string]interface{}{
+		"accountId": f.AccountID,
+		"groups":    []interface{}{},
+		"mutual":    0,
+		"alias":     f.Alias,
+		"note":      f.Note,
+		"favorite":  f.Favorite,
+		"created":   f.Created,
+	}
+}
+
+func pendingFriendJSON(f friends.Friend) map[string]interface{} {
+	return map[string]interface{}{
+		"accountId": f.AccountID,
+		"mutual":    0,
+		"favorite":  f.Favorite,
+		"created":   f.Created,
+	}
+}
+
+func blockedJSON(b friends.Blocked) map[string]interface{} {
+	return map[string]interface{}{
+		"accountId": b.AccountID,
+		"created":   b.Created,
+	}
+}
+
+func mapFriends(list []friends.Friend, fn func(friends.Friend) map[string]interface{}) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, f := range list {
+		out = append(out, fn(f))
+	}
+	return out
+}
+
+func blocklistJSON(list []friends.Blocked) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, b := range list {
+		out = append(out, blockedJSON(b))
+	}
+	return out
+}
+
+func FriendsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+
+	response := map[string]interface{}{
+		"friends":   mapFriends(list.Filter(friends.StatusAccepted, ""), acceptedFriendJSON),
+		"incoming":  mapFriends(list.Filter(friends.StatusPending, friends.DirectionInbound), pendingFriendJSON),
+		"outgoing":  mapFriends(list.Filter(friends.StatusPending, friends.DirectionOutbound), pendingFriendJSON),
+		"suggested": []interface{}{},
+		"blocklist": blocklistJSON(list.Blocked),
+		"settings": map[string]interface{}{
+			"acceptInvites": "public",
+		},
+		"limitsReached": map[string]interface{}{
+			"incoming": false,
+			"outgoing": false,
+			"accepted": false,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func FriendsListHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapFriends(list.Filter(friends.StatusAccepted, ""), acceptedFriendJSON))
+}
+
+func IncomingFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapFriends(list.Filter(friends.StatusPending, friends.DirectionInbound), pendingFriendJSON))
+}
+
+func OutgoingFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapFriends(list.Filter(friends.StatusPending, friends.DirectionOutbound), pendingFriendJSON))
+}
+
+func BlocklistHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocklistJSON(list.Blocked))
+}
+
+func FriendSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"acceptInvites": "public",
+	})
+}
+
+func LegacyFriendsListHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+
+	response := []map[string]interface{}{}
+	for _, f := range list.Friends {
+		response = append(response, map[string]interface{}{
+			"accountId": f.AccountID,
+			"status":    f.Status,
+			"direction": f.Direction,
+			"created":   f.Created,
+			"favorite":  f.Favorite,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func LegacyBlocklistHandler(w http.ResponseWriter, r *http.Request) {
+	list := friends.Get(mux.Vars(r)["accountId"])
+
+	blocked := []string{}
+	for _, b := range list.Blocked {
+		blocked = append(blocked, b.AccountID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"blockedUsers": blocked,
+	})
+}
+
+func sendFriendsError(w http.ResponseWriter, err error) {
+	switch err {
+	case friends.ErrNotFound, friends.ErrNotBlocked:
+		structs.SendDetailedError(w, structs.Errors["friendship_not_found"], http.StatusNotFound)
+	case friends.ErrAlreadyFriends:
+		structs.SendDetailedError(w, structs.Errors["duplicate_friendship"], http.StatusConflict)
+	case friends.ErrAlreadyRequested:
+		structs.SendDetailedError(w, structs.Errors["friend_request_sent"], http.StatusConflict)
+	case friends.ErrInvalidAccount:
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("accountId"), http.StatusBadRequest)
+	case friends.ErrSelfFriend:
+		structs.SendDetailedError(w, structs.Errors["self_friend"], http.StatusBadRequest)
+	case friends.ErrBlocked:
+		structs.SendDetailedError(w, structs.Errors["friend_blocked"], http.StatusForbidden)
+	default:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
+	}
+}
+
+func AddFriendHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if account.Get(vars["friendId"]) == nil {
+		structs.SendDetailedError(w, structs.Errors["account_not_found"].With(vars["friendId"]), http.StatusNotFound)
+		return
+	}
+
+	action, err := friends.Add(vars["accountId"], vars["friendId"])
+	if err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	if action == friends.Requested && bot.IsBot(vars["friendId"]) {
+		// The lobby bot accepts every request right away.
+		if accepted, err := friends.Add(vars["friendId"], vars["accountId"]); err == nil {
+			action = accepted
+		}
+	}
+
+	status := friends.StatusPending
+	if action == friends.Accepted {
+		status = friends.StatusAccepted
+	}
+	notifyFriendship(vars["accountId"], vars["friendId"], status)
+	if action == friends.Accepted {
+		xmpp.ExchangePresence(vars["accountId"], vars["friendId"])
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func RemoveFriendHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	removed, err := friends.Remove(vars["accountId"], vars["friendId"])
+	if err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	notifyFriendRemoval(vars["accountId"], vars["friendId"], friends.RemovalReason(removed))
+	if removed.Status == friends.StatusAccepted {
+		xmpp.WithdrawPresence(vars["accountId"], vars["friendId"])
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func BlockFriendHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	removed, err := friends.Block(vars["accountId"], vars["friendId"])
+	if err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	if removed {
+		notifyFriendRemoval(vars["accountId"], vars["friendId"], "DELETED")
+		xmpp.WithdrawPresence(vars["accountId"], vars["friendId"])
+	}
+	notifyBlocklist(vars["accountId"], vars["friendId"], true)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UnblockFriendHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := friends.Unblock(vars["accountId"], vars["friendId"]); err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	notifyBlocklist(vars["accountId"], vars["friendId"], false)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readTextBody reads a plain text body such as an alias or note, capped at
+// max characters.
+func readTextBody(r *http.Request, max int) (string, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(max)*4+1))
+	if err != nil {
+		return "", false
+	}
+	text := strings.TrimSpace(string(body))
+	if text == "" || len([]rune(text)) > max {
+		return "", false
+	}
+	return text, true
+}
+
+func SetFriendAliasHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	alias, ok := readTextBody(r, 16)
+	if !ok {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("alias"), http.StatusBadRequest)
+		return
+	}
+	if err := friends.SetAlias(vars["accountId"], vars["friendId"], alias); err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ClearFriendAliasHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := friends.SetAlias(vars["accountId"], vars["friendId"], ""); err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func SetFriendNoteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	note, ok := readTextBody(r, 255)
+	if !ok {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("note"), http.StatusBadRequest)
+		return
+	}
+	if err := friends.SetNote(vars["accountId"], vars["friendId"], note); err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ClearFriendNoteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := friends.SetNote(vars["accountId"], vars["friendId"], ""); err != nil {
+		sendFriendsError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func friendEntry(owner, other string) (friends.Friend, bool) {
+	for _, f := range friends.Get(owner).Friends {
+		if f.AccountID == other {
+			return f, true
+		}
+	}
+	return friends.Friend{}, false
+}
+
+// notifyFriendship tells both accounts about a new request or an accepted
+// friendship, in both the current and the legacy message format.
+func notifyFriendship(accountId, friendId, status string) {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+
+	for _, pair := range [][2]string{{accountId, friendId}, {friendId, accountId}} {
+		owner, other := pair[0], pair[1]
+		f, ok := friendEntry(owner, other)
+		if !ok {
+			continue
+		}
+
+		xmpp.SendMessage(owner, map[string]interface{}{
+			"payload": map[string]interface{}{
+				"accountId": f.AccountID,
+				"status":    f.Status,
+				"direction": f.Direction,
+				"created":   f.Created,
+				"favorite":  f.Favorite,
+			},
+			"type":      "com.epicgames.friends.core.apiobjects.Friend",
+			"timestamp": timestamp,
+		})
+		xmpp.SendMessage(owner, map[string]interface{}{
+			"type":      "FRIENDSHIP_REQUEST",
+			"timestamp": timestamp,
+			"from":      accountId,
+			"to":        friendId,
+			"status":    status,
+		})
+	}
+}
+
+func notifyFriendRemoval(accountId, friendId, reason string) {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+
+	for _, pair := range [][2]string{{accountId, friendId}, {friendId, accountId}} {
+		owner, other := pair[0], pair[1]
+
+		xmpp.SendMessage(owner, map[string]interface{}{
+			"payload": map[string]interface{}{
+				"accountId": other,
+				"reason":    reason,
+			},
+			"type":      "com.epicgames.friends.core.apiobjects.FriendRemoval",
+			"timestamp": timestamp,
+		})
+		xmpp.SendMessage(owner, map[string]interface{}{
+			"type":      "FRIENDSHIP_REMOVE",
+			"timestamp": timestamp,
+			"from":      accountId,
+			"to":        friendId,
+			"reason":    reason,
+		})
+	}
+}
+
+func notifyBlocklist(accountId, blockedId string, blocked bool) {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+
+	entryType, status := "com.epicgames.friends.core.apiobjects.BlockListEntryAdded", "BLOCKED"
+	if !blocked {
+		entryType, status = "com.epicgames.friends.core.apiobjects.BlockListEntryRemoved", "UNBLOCKED"
+	}
+
+	xmpp.SendMessage(accountId, map[string]interface{}{
+		"payload": map[string]interface{}{
+			"accountId": blockedId,
+		},
+		"type":      entryType,
+		"timestamp": timestamp,
+	})
+	xmpp.SendMessage(accountId, map[string]interface{}{
+		"type":      "USER_BLOCKLIST_UPDATE",
+		"timestamp": timestamp,
+		"ownerId":   accountId,
+		"accountId": blockedId,
+		"status":    status,
+	})
+}
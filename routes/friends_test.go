@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+
+	"neonite-go/account"
+	"neonite-go/routes/xmpp"
+)
+
+// recordingConn is an XMPP session that keeps what it is sent.
+type recordingConn struct {
+	accountId string
+
+	mu      sync.Mutex
+	stanzas []string
+}
+
+func (c *recordingConn) AccountID() string { return c.accountId }
+func (c *recordingConn) JID() string       { return c.accountId + "@" + xmpp.Domain + "/V2:Fortnite:WIN" }
+
+func (c *recordingConn) Send(stanza string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stanzas = append(c.stanzas, stanza)
+	return nil
+}
+
+var bodyPattern = regexp.MustCompile(`<body>(.*)</body>`)
+
+// messages decodes the JSON bodies of the messages the session got.
+func (c *recordingConn) messages(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []map[string]interface{}
+	for _, stanza := range c.stanzas {
+		m := bodyPattern.FindStringSubmatch(stanza)
+		if m == nil {
+			continue
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(m[1]), &body); err != nil {
+			t.Fatalf("message body is not JSON: %s", m[1])
+		}
+		list = append(list, body)
+	}
+	return list
+}
+
+func TestFriendRequestNotifies(t *testing.T) {
+	r := testRouter(RegisterFriendsRoutes)
+	for _, id := range []string{"notify-alice", "notify-bob"} {
+		account.Record(id, id)
+	}
+	bob := &recordingConn{accountId: "notify-bob"}
+	xmpp.Register(bob)
+	defer xmpp.Unregister(bob)
+
+	w := call(r, "POST", "/friends/api/v1/notify-alice/friends/notify-bob", login("notify-alice"), "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("friend request: %d %s", w.Code, w.Body)
+	}
+
+	var got []string
+	for _, m := range bob.messages(t) {
+		got = append(got, m["type"].(string))
+	}
+	want := []string{"com.epicgames.friends.core.apiobjects.Friend", "FRIENDSHIP_REQUEST"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("bob got %v, want %v", got, want)
+	}
+}
+
+func TestFriendsRequireOwnAccount(t *testing.T) {
+	r := testRouter(RegisterFriendsRoutes)
+	account.Record("guard-alice", "guard-alice")
+	account.Record("guard-bob", "guard-bob")
+	bob := login("guard-bob")
+
+	tests := []struct {
+		name, method, path, token string
+		want                      int
+	}{
+		{"list without token", "GET", "/friends/api/v1/guard-alice/summary", "", http.StatusUnauthorized},
+		{"list someone else's", "GET", "/friends/api/v1/guard-alice/summary", bob, http.StatusForbidden},
+		{"add without token", "POST", "/friends/api/v1/guard-alice/friends/guard-bob", "", http.StatusUnauthorized},
+		{"add as someone else", "POST", "/friends/api/v1/guard-alice/friends/guard-bob", bob, http.StatusForbidden},
+		{"legacy block as someone else", "POST", "/friends/api/public/blocklist/guard-alice/guard-bob", bob, http.StatusForbidden},
+		{"block a path", "POST", "/friends/api/v1/guard-bob/blocklist/..%5Cguard-alice", bob, http.StatusBadRequest},
+		{"add as self", "POST", "/friends/api/v1/guard-bob/friends/guard-alice", bob, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := call(r, tt.method, tt.path, tt.token, ""); w.Code != tt.want {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
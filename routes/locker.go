@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"neonite-go/locker"
+	"neonite-go/party"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterLockerRoutes(r *mux.Router) {
+	r.HandleFunc("/api/v1/locker/{accountId}/copy/{targetId}", CopyLoadoutHandler).Methods("POST")
+}
+
+// CopyLoadoutHandler equips what a party member is wearing in the caller's
+// sandbox loadout.
+func CopyLoadoutHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["accountId"]) {
+		return
+	}
+
+	p := party.GetUserParty(vars["accountId"])
+	if p == nil {
+		sendPartyError(w, party.ErrPartyNotFound)
+		return
+	}
+	loadout, err := locker.MemberLoadout(p, vars["targetId"])
+	if err == nil {
+		var result locker.CopyResult
+		if result, err = locker.Copy(vars["accountId"], loadout); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+	}
+
+	switch err {
+	case locker.ErrNoLoadout:
+		structs.SendDetailedError(w, structs.Errors["loadout_not_found"].With(vars["targetId"]), http.StatusNotFound)
+	case party.ErrMemberNotFound:
+		sendPartyError(w, err)
+	default:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCopyLoadoutRequiresOwner(t *testing.T) {
+	r := testRouter(RegisterLockerRoutes)
+	owner := login("locker-owner")
+	other := login("locker-other")
+
+	tests := []struct {
+		name, token string
+		want        int
+	}{
+		{"without token", "", http.StatusUnauthorized},
+		{"with unknown token", "not-a-token", http.StatusUnauthorized},
+		{"by someone else", other, http.StatusForbidden},
+		{"by owner outside a party", owner, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := call(r, "POST", "/api/v1/locker/locker-owner/copy/locker-other", tt.token, ""); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
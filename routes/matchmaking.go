@@ -0,0 +1,327 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonite-go/matchmaking"
+	"neonite-go/party"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	mmsStepDelay    = 500 * time.Millisecond
+	mmsPollInterval = time.Second
+	mmsWriteTimeout = 10 * time.Second
+	mmsCloseDelay   = 10 * time.Second
+)
+
+var mmsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func RegisterMatchmakingRoutes(r *mux.Router) {
+	r.HandleFunc("/fortnite/api/game/v2/matchmakingservice/ticket/player/{accountId}", MatchmakingTicketHandler).Methods("GET")
+	r.HandleFunc("/matchmaking", MatchmakerHandler).Methods("GET")
+	r.HandleFunc("/fortnite/api/matchmaking/session/{sessionId}", MatchmakingSessionHandler).Methods("GET")
+	r.HandleFunc("/fortnite/api/matchmaking/session/{sessionId}/join", JoinSessionHandler).Methods("POST")
+
+	r.HandleFunc("/api/v1/admin/servers", GameServersHandler).Methods("GET")
+	r.HandleFunc("/api/v1/admin/servers", RegisterGameServerHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/servers/{sessionId}/heartbeat", GameServerHeartbeatHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/servers/{sessionId}", UnregisterGameServerHandler).Methods("DELETE")
+}
+
+// MatchmakingTicketHandler issues the signed ticket the client takes to the
+// matchmaker. The bucket id names the playlist and region, and
+// partyPlayerIds the party members queueing along, who must all be in the
+// caller's party.
+func MatchmakingTicketHandler(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+	if !requireAccount(w, r, accountId) {
+		return
+	}
+	q := r.URL.Query()
+	var members []string
+	if ids := q.Get("partyPlayerIds"); ids != "" {
+		members = strings.Split(ids, ",")
+	}
+	p := party.GetUserParty(accountId)
+	for _, id := range members {
+		if id != "" && id != accountId && (p == nil || p.Member(id) == nil) {
+			structs.SendDetailedError(w, structs.Errors["invalid_request"].With("partyPlayerIds"), http.StatusBadRequest)
+			return
+		}
+	}
+	ticket, err := matchmaking.NewTicket(accountId, members, q.Get("bucketId"), q.Get("player.platform"))
+	if err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid bucketId"), http.StatusBadRequest)
+		return
+	}
+
+	serviceURL := structs.Settings.Matchmaking.ServiceURL
+	if serviceURL == "" {
+		serviceURL = "ws://" + r.Host + "/matchmaking"
+	}
+	payload, signature := ticket.Sign()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"serviceUrl": serviceURL,
+		"ticketType": "mms-player",
+		"payload":    payload,
+		"signature":  signature,
+	})
+}
+
+// mmsTicket reads the ticket from an "Epic-Signed mms-player <payload>
+// <signature>" Authorization header.
+func mmsTicket(r *http.Request) (matchmaking.Ticket, error) {
+	fields := strings.Fields(r.Header.Get("Authorization"))
+	if len(fields) != 4 || fields[0] != "Epic-Signed" || fields[1] != "mms-player" {
+		return matchmaking.Ticket{}, matchmaking.ErrBadSignature
+	}
+	return matchmaking.Verify(fields[2], fields[3])
+}
+
+// MatchmakerHandler walks the client through matchmaking over a WebSocket:
+// it connects, waits for its party, queues until a game server has room
+// for everyone, and is then told which session to join.
+func MatchmakerHandler(w http.ResponseWriter, r *http.Request) {
+	ticket, err := mmsTicket(r)
+	if err == nil {
+		err = matchmaking.Claim(ticket)
+	}
+	if err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_token"].With(err.Error()), http.StatusUnauthorized)
+		return
+	}
+	conn, err := mmsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// The client sends nothing; reading only tells us when it leaves.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(name string, payload map[string]interface{}) bool {
+		conn.SetWriteDeadline(time.Now().Add(mmsWriteTimeout))
+		return conn.WriteJSON(map[string]interface{}{"name": name, "payload": payload}) == nil
+	}
+	status := func(payload map[string]interface{}) bool {
+		return send("StatusUpdate", payload)
+	}
+	wait := func(d time.Duration) bool {
+		select {
+		case <-gone:
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	players := ticket.Players()
+	if !status(map[string]interface{}{"state": "Connecting"}) || !wait(mmsStepDelay) {
+		return
+	}
+	if !status(map[string]interface{}{
+		"state":            "Waiting",
+		"totalPlayers":     players,
+		"connectedPlayers": players,
+	}) || !wait(mmsStepDelay) {
+		return
+	}
+
+	matchmaking.Enqueue(ticket)
+	defer matchmaking.Dequeue(ticket)
+	var assignment matchmaking.Assignment
+	for {
+		a, ok := matchmaking.Assign(ticket)
+		if ok {
+			assignment = a
+			break
+		}
+		if !status(map[string]interface{}{
+			"state":            "Queued",
+			"ticketId":         ticket.ID,
+			"queuedPlayers":    matchmaking.Queued(ticket),
+			"estimatedWaitSec": 0,
+			"status":           0,
+		}) || !wait(mmsPollInterval) {
+			return
+		}
+	}
+	matchmaking.Dequeue(ticket)
+	structs.NeoLog("[Matchmaking] " + ticket.AccountID + " assigned to " + assignment.SessionID + " (" + ticket.Playlist + ", " + ticket.Region + ")")
+
+	if !status(map[string]interface{}{
+		"state":   "SessionAssignment",
+		"matchId": assignment.MatchID,
+	}) || !wait(mmsStepDelay) || !send("Play", map[string]interface{}{
+		"matchId":      assignment.MatchID,
+		"sessionId":    assignment.SessionID,
+		"joinDelaySec": 1,
+	}) {
+		// The player never heard where to go, so their slot is free again.
+		matchmaking.Release(ticket, assignment.SessionID)
+		return
+	}
+	// Give the client a moment to hang up before closing on it.
+	wait(mmsCloseDelay)
+}
+
+func sendMatchmakingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, matchmaking.ErrServerNotFound):
+		structs.SendDetailedError(w, structs.Errors["session_not_found"], http.StatusNotFound)
+	case errors.Is(err, matchmaking.ErrInvalidServer):
+		structs.SendDetailedError(w, structs.Errors["invalid_server"], http.StatusBadRequest)
+	case errors.Is(err, matchmaking.ErrInvalidState):
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("state must be open, started or closed"), http.StatusBadRequest)
+	default:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
+	}
+}
+
+// MatchmakingSessionHandler tells the client where the session it was
+// matched into is running.
+func MatchmakingSessionHandler(w http.ResponseWriter, r *http.Request) {
+	s, err := matchmaking.Session(mux.Vars(r)["sessionId"])
+	if err != nil {
+		sendMatchmakingError(w, err)
+		return
+	}
+	region := strings.ToUpper(s.Region)
+	if region == "" {
+		region = "NAE"
+	}
+	serverName := "[DS]neonite-" + strings.ToLower(region) + "-" + strconv.Itoa(s.Port)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                 s.SessionID,
+		"ownerId":            strings.ToUpper(s.SessionID),
+		"ownerName":          serverName,
+		"serverName":         serverName,
+		"serverAddress":      s.IP,
+		"serverPort":         s.Port,
+		"maxPublicPlayers":   s.Capacity,
+		"openPublicPlayers":  max(s.Free(), 0),
+		"maxPrivatePlayers":  0,
+		"openPrivatePlayers": 0,
+		"attributes": map[string]interface{}{
+			"REGION_s":            region,
+			"GAMEMODE_s":          "FORTATHENA",
+			"ALLOWBROADCASTING_b": true,
+			"SUBREGION_s":         region,
+			"DCID_s":              strings.ToUpper(serverName),
+			"tenant_s":            "Fortnite",
+			"TENANT_s":            "Fortnite",
+			"MATCHMAKINGPOOL_s":   "Any",
+			"HOTFIXVERSION_i":     0,
+			"PLAYLISTNAME_s":      s.Playlist,
+			"SESSIONKEY_s":        s.SessionID,
+			"BEACONPORT_i":        15009,
+		},
+		"publicPlayers":                   []string{},
+		"privatePlayers":                  []string{},
+		"totalPlayers":                    len(s.Players),
+		"allowJoinInProgress":             false,
+		"shouldAdvertise":                 false,
+		"isDedicated":                     false,
+		"usesStats":                       false,
+		"allowInvites":                    false,
+		"usesPresence":                    false,
+		"allowJoinViaPresence":            true,
+		"allowJoinViaPresenceFriendsOnly": false,
+		"buildUniqueId":                   s.NetCL,
+		"lastUpdated":                     time.Now().UTC().Format(storeTimeFormat),
+		"started":                         s.State == matchmaking.StateStarted,
+	})
+}
+
+// JoinSessionHandler acknowledges a client joining its session; its slot
+// was taken when it was matched.
+func JoinSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := matchmaking.Session(mux.Vars(r)["sessionId"]); err != nil {
+		sendMatchmakingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GameServersHandler lists the game servers in the matchmaking pool.
+func GameServersHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(matchmaking.Servers())
+}
+
+// RegisterGameServerHandler adds a dedicated server to the pool. The
+// response carries the session id it heartbeats with.
+func RegisterGameServerHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var s matchmaking.Server
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	s, err := matchmaking.Register(s)
+	if err != nil {
+		sendMatchmakingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+// GameServerHeartbeatHandler keeps a dedicated server in the pool and
+// updates its state and, when sent, the players it holds.
+func GameServerHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		State   string   `json:"state"`
+		Players []string `json:"players"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+			return
+		}
+	}
+	s, err := matchmaking.Heartbeat(mux.Vars(r)["sessionId"], body.State, body.Players)
+	if err != nil {
+		sendMatchmakingError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(s)
+}
+
+// UnregisterGameServerHandler takes a dedicated server out of the pool.
+func UnregisterGameServerHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := matchmaking.Unregister(mux.Vars(r)["sessionId"]); err != nil {
+		sendMatchmakingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
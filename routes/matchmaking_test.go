@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"neonite-go/party"
+)
+
+// joinInfo is what a client sends to join a party as accountId.
+func joinInfo(accountId string) party.JoinInfo {
+	var join party.JoinInfo
+	join.Connection.ID = accountId + "@prod.ol.epicgames.com/V2:Fortnite"
+	return join
+}
+
+func TestMatchmakingTicket(t *testing.T) {
+	r := testRouter(RegisterMatchmakingRoutes)
+	captain := login("mm-captain")
+	member := login("mm-member")
+	p := party.Create(party.Config{Joinability: party.JoinabilityOpen}, nil, joinInfo("mm-captain"))
+	if _, err := party.Join(p.ID, "mm-member", joinInfo("mm-member")); err != nil {
+		t.Fatal(err)
+	}
+
+	base := "/fortnite/api/game/v2/matchmakingservice/ticket/player/mm-captain?bucketId=1:0:NAE:playlist_defaultduo"
+	tests := []struct {
+		name, path, token string
+		want              int
+	}{
+		{"without token", base, "", http.StatusUnauthorized},
+		{"for someone else", base, member, http.StatusForbidden},
+		{"alone", base, captain, http.StatusOK},
+		{"with party member", base + "&partyPlayerIds=mm-captain,mm-member", captain, http.StatusOK},
+		{"with outsider", base + "&partyPlayerIds=mm-captain,mm-outsider", captain, http.StatusBadRequest},
+		{"bad bucket", "/fortnite/api/game/v2/matchmakingservice/ticket/player/mm-captain?bucketId=x", captain, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := call(r, "GET", tt.path, tt.token, ""); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestMatchmakerRefusesReusedTicket(t *testing.T) {
+	r := testRouter(RegisterMatchmakingRoutes)
+	token := login("mm-solo")
+	w := call(r, "GET", "/fortnite/api/game/v2/matchmakingservice/ticket/player/mm-solo?bucketId=1:0:NAE:playlist_defaultsolo", token, "")
+	var ticket struct {
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &ticket); err != nil {
+		t.Fatal(err)
+	}
+
+	matchmaker := func(auth string) int {
+		req := httptest.NewRequest("GET", "/matchmaking", nil)
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := matchmaker("Epic-Signed mms-player " + ticket.Payload + " bad"); code != http.StatusUnauthorized {
+		t.Errorf("bad signature: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	// The first use gets past the ticket check and fails the WebSocket
+	// upgrade, since this is a plain request.
+	if code := matchmaker("Epic-Signed mms-player " + ticket.Payload + " " + ticket.Signature); code != http.StatusBadRequest {
+		t.Errorf("first use: status %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := matchmaker("Epic-Signed mms-player " + ticket.Payload + " " + ticket.Signature); code != http.StatusUnauthorized {
+		t.Errorf("reuse: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -2,13 +2,12 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"neonite-go/profile"
 	"neonite-go/structs"
 	"neonite-go/structs/utils"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -43,14 +42,29 @@ func convertProfileChangesToInterfaces(changes []structs.ProfileChange) []interf
 	return result
 }
 
+// convertInterfacesToProfileChanges also takes the change maps the profile
+// helpers append, naming item attribute changes after the attribute.
 func convertInterfacesToProfileChanges(changes []interface{}) []structs.ProfileChange {
 	result := make([]structs.ProfileChange, len(changes))
 	for i, v := range changes {
-		result[i] = v.(structs.ProfileChange)
+		switch v := v.(type) {
+		case structs.ProfileChange:
+			result[i] = v
+		case map[string]interface{}:
+			result[i].ChangeType, _ = v["changeType"].(string)
+			if result[i].Name, _ = v["name"].(string); result[i].Name == "" {
+				result[i].Name, _ = v["attribute"].(string)
+			}
+			result[i].Value = v["value"]
+		}
 	}
 	return result
 }
 
+// errNoProfileChanges ends a profile update without saving when a command
+// changed nothing.
+var errNoProfileChanges = errors.New("no profile changes")
+
 func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	accountId := chi.URLParam(r, "accountId")
@@ -60,29 +74,15 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 		profileId = "common_core"
 	}
 
-	getOrCreateProfile := func(profileId string) (*profile.ProfileData, *structs.ProfileResponse, error) {
-		data, err := profile.ReadProfile(accountId, profileId)
-		if err != nil || data == nil {
-			tmpl, err := profile.ReadProfileTemplate(profileId)
-			if err != nil || tmpl == nil {
-				return nil, nil, structs.NewAPIError("operation_forbidden").With(profileId)
-			}
-			tmpl.Created = time.Now().UTC().Format(time.RFC3339)
-			tmpl.Updated = tmpl.Created
-			tmpl.AccountID = accountId
-			tmpl.ID = accountId
-
-			dir := filepath.Join("config", accountId, "profiles")
-			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-				return nil, nil, fmt.Errorf("failed to create profile directory: %w", err)
-			}
-			if err := profile.SaveProfile(accountId, profileId, tmpl); err != nil {
-				return nil, nil, err
-			}
-			data = tmpl
-		}
+	var reqBody CommandRequest
+	_ = json.NewDecoder(r.Body).Decode(&reqBody)
+	build := structs.GetBuildInfo(r)
 
-		resp := &structs.ProfileResponse{
+	// The command runs inside profile.Update so it cannot race other writers
+	// of the same profile, such as gifts from the lobby bot.
+	var response *structs.ProfileResponse
+	data, err := profile.Update(accountId, profileId, func(data *profile.ProfileData) error {
+		response = &structs.ProfileResponse{
 			ProfileRevision:            data.Rvn,
 			ProfileId:                  profileId,
 			ProfileChangesBaseRevision: data.Rvn,
@@ -90,22 +90,53 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 			ResponseVersion:            1,
 			ServerTime:                 time.Now().UTC().Format(time.RFC3339),
 		}
-		return data, resp, nil
+		if profileId == "athena" && build.Known {
+			syncSeason(data, build.Season, response)
+		}
+		if err := runProfileCommand(command, profileId, data, &reqBody, response); err != nil {
+			return err
+		}
+		if len(response.ProfileChanges) == 0 {
+			return errNoProfileChanges
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errNoProfileChanges):
+	case err != nil:
+		var apiErr structs.APIError
+		if !errors.As(err, &apiErr) {
+			err = structs.NewAPIError("operation_forbidden").With(profileId)
+		}
+		utils.WriteError(w, err)
+		return
+	default:
+		response.ProfileRevision = data.Rvn
+		response.ProfileCommandRevision = data.CommandRevision
 	}
 
-	data, response, err := getOrCreateProfile(profileId)
-	if err != nil {
-		utils.WriteError(w, err)
+	json.NewEncoder(w).Encode(response)
+}
+
+// syncSeason makes the athena profile report the season of the client's
+// build.
+func syncSeason(data *profile.ProfileData, season int, response *structs.ProfileResponse) {
+	if n, ok := data.Stats.Attributes["season_num"].(float64); ok && int(n) == season {
 		return
 	}
-	var reqBody CommandRequest
-	_ = json.NewDecoder(r.Body).Decode(&reqBody)
+	if n, ok := data.Stats.Attributes["season_num"].(int); ok && n == season {
+		return
+	}
+	changesInterface := convertProfileChangesToInterfaces(response.ProfileChanges)
+	profile.ModifyStat(data, "season_num", season, &changesInterface)
+	response.ProfileChanges = convertInterfacesToProfileChanges(changesInterface)
+}
 
+func runProfileCommand(command, profileId string, data *profile.ProfileData, reqBody *CommandRequest, response *structs.ProfileResponse) error {
 	switch command {
 	case "CopyCosmeticLoadout":
 		if profileId != "athena" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		if reqBody.SourceIndex == 0 {
 			data.Items[fmt.Sprintf("neoset%d_loadout", reqBody.TargetIndex)] = data.Items["sandbox_loadout"]
@@ -114,8 +145,7 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			item := data.Items[fmt.Sprintf("neoset%d_loadout", reqBody.SourceIndex)]
 			if item == nil {
-				utils.WriteError(w, structs.NewAPIError("item_not_found").With(reqBody.LockerItem))
-				return
+				return structs.NewAPIError("item_not_found").With(reqBody.LockerItem)
 			}
 			data.Stats.Attributes["active_loadout_index"] = reqBody.SourceIndex
 			data.Stats.Attributes["last_applied_loadout"] = fmt.Sprintf("neoset%d_loadout", reqBody.SourceIndex)
@@ -123,16 +153,14 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	case "DeleteCosmeticLoadout":
 		if profileId != "athena" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		loadouts := data.Stats.Attributes["loadouts"].([]string)
 		loadouts[reqBody.TargetIndex] = ""
 		data.Stats.Attributes["loadouts"] = loadouts
 	case "SetMtxPlatform":
 		if profileId != "common_core" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		response.ProfileChanges = append(response.ProfileChanges, structs.ProfileChange{
 			ChangeType: "statModified",
@@ -141,16 +169,14 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	case "SetReceiveGiftsEnabled":
 		if profileId != "common_core" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		changesInterface := convertProfileChangesToInterfaces(response.ProfileChanges)
 		profile.ModifyStat(data, "allowed_to_receive_gifts", reqBody.BReceiveGifts, &changesInterface)
 		response.ProfileChanges = convertInterfacesToProfileChanges(changesInterface)
 	case "SetItemFavoriteStatus":
 		if profileId != "athena" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		item := data.Items[reqBody.TargetItemId]
 		if item != nil && item.Attributes["favorite"] != reqBody.BFavorite {
@@ -160,8 +186,7 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	case "SetItemFavoriteStatusBatch":
 		if profileId != "athena" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		for i, itemId := range reqBody.ItemIds {
 			if i < len(reqBody.ItemFavStatus) {
@@ -172,8 +197,7 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	case "SetItemArchivedStatusBatch":
 		if profileId != "athena" {
-			utils.WriteError(w, structs.NewAPIError("invalid_profile").With(profileId))
-			return
+			return structs.NewAPIError("invalid_profile").With(profileId)
 		}
 		for _, itemId := range reqBody.ItemIds {
 			changesInterface := convertProfileChangesToInterfaces(response.ProfileChanges)
@@ -181,16 +205,7 @@ func ProfileCommandHandler(w http.ResponseWriter, r *http.Request) {
 			response.ProfileChanges = convertInterfacesToProfileChanges(changesInterface)
 		}
 	default:
-		utils.WriteError(w, structs.NewAPIError("unsupported_command").With(command))
-		return
-	}
-
-	if len(response.ProfileChanges) > 0 {
-		profile.BumpRvn(data)
-		response.ProfileRevision = data.Rvn
-		response.ProfileCommandRevision = data.CommandRevision
-		profile.SaveProfile(accountId, profileId, data)
+		return structs.NewAPIError("unsupported_command").With(command)
 	}
-
-	json.NewEncoder(w).Encode(response)
+	return nil
 }
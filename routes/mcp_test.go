@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"neonite-go/profile"
+	"neonite-go/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// mcpRouter serves profile commands on a fresh athena template.
+func mcpRouter(t *testing.T) http.Handler {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join("config", "templates"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	template := `{"items":{"AthenaCharacter:cid_a":{"templateId":"AthenaCharacter:cid_a","attributes":{"favorite":false},"quantity":1}}}`
+	if err := os.WriteFile(filepath.Join("config", "templates", "athena.json"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(filepath.Join("config", "templates", "athena.json")) })
+
+	r := chi.NewRouter()
+	r.Post("/profile/{accountId}/{command}", ProfileCommandHandler)
+	return r
+}
+
+func TestProfileCommandSaves(t *testing.T) {
+	r := mcpRouter(t)
+
+	for _, tt := range []struct {
+		command, body string
+		status, rvn   int
+	}{
+		{"SetItemFavoriteStatus", `{"targetItemId":"AthenaCharacter:cid_a","bFavorite":true}`, http.StatusOK, 1},
+		// Nothing changed, so nothing is saved.
+		{"SetItemFavoriteStatus", `{"targetItemId":"AthenaCharacter:cid_a","bFavorite":true}`, http.StatusOK, 1},
+		{"NoSuchCommand", `{}`, http.StatusBadRequest, 1},
+	} {
+		w := call(r, "POST", "/profile/mcpuser/"+tt.command+"?profileId=athena", "", tt.body)
+		if w.Code != tt.status {
+			t.Fatalf("%s: status %d, want %d: %s", tt.command, w.Code, tt.status, w.Body)
+		}
+		data, err := profile.ReadProfile("mcpuser", "athena")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.Rvn != tt.rvn {
+			t.Errorf("%s: rvn %d, want %d", tt.command, data.Rvn, tt.rvn)
+		}
+	}
+}
+
+func TestProfileCommandReportsSeason(t *testing.T) {
+	r := mcpRouter(t)
+	command := func(userAgent string) []structs.ProfileChange {
+		req := httptest.NewRequest("POST", "/profile/mcpseason/SetItemArchivedStatusBatch?profileId=athena",
+			strings.NewReader(`{"itemIds":[],"archived":true}`))
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp structs.ProfileResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%v: %s", err, w.Body)
+		}
+		return resp.ProfileChanges
+	}
+
+	ua := "Fortnite/++Fortnite+Release-12.41-CL-12905909 Windows/10"
+	changes := command(ua)
+	if len(changes) != 1 || changes[0].Name != "season_num" || changes[0].Value != float64(12) {
+		t.Fatalf("first command changes = %+v, want season_num 12", changes)
+	}
+	if changes := command(ua); len(changes) != 0 {
+		t.Errorf("same build again changed %+v", changes)
+	}
+	if changes := command("curl/8.0"); len(changes) != 0 {
+		t.Errorf("unknown build changed %+v", changes)
+	}
+}
@@ -3,264 +3,419 @@ package routes
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 	"time"
 
+	"neonite-go/account"
+	"neonite-go/party"
+	"neonite-go/routes/xmpp"
+	"neonite-go/structs"
+
 	"github.com/gorilla/mux"
 )
 
-type MemberConnectionMeta struct {
-	Platform string `json:"urn:epic:conn:platform_s"`
-	Type     string `json:"urn:epic:conn:type_s"`
+type PartyRequest struct {
+	Config   party.Config      `json:"config"`
+	Meta     map[string]string `json:"meta"`
+	JoinInfo party.JoinInfo    `json:"join_info"`
 }
 
-type MemberConnection struct {
-	ID          string            `json:"id"`
-	ConnectedAt time.Time         `json:"connected_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
-	YieldLead   bool              `json:"yield_leadership"`
-	Meta        MemberConnectionMeta `json:"meta"`
+type MetaPatch struct {
+	Delete []string          `json:"delete"`
+	Update map[string]string `json:"update"`
 }
 
-type MemberMeta struct {
-	DisplayName string `json:"urn:epic:member:dn_s,omitempty"`
+type PartyPatchRequest struct {
+	Config   json.RawMessage `json:"config"`
+	Meta     MetaPatch       `json:"meta"`
+	Revision int             `json:"revision"`
 }
 
-type Member struct {
-	AccountID   string             `json:"account_id"`
-	Meta        MemberMeta         `json:"meta"`
-	Connections []MemberConnection `json:"connections"`
-	Revision    int                `json:"revision"`
-	UpdatedAt   time.Time          `json:"updated_at"`
-	JoinedAt    time.Time          `json:"joined_at"`
-	Role        string             `json:"role"`
+type MemberMetaPatchRequest struct {
+	Delete   []string          `json:"delete"`
+	Update   map[string]string `json:"update"`
+	Revision int               `json:"revision"`
 }
 
-type PartyConfig struct {
-	Type            string `json:"type"`
-	Joinability     string `json:"joinability"`
-	Discoverability string `json:"discoverability"`
-	SubType         string `json:"sub_type"`
-	MaxSize         int    `json:"max_size"`
-	InviteTTL       int    `json:"invite_ttl"`
-	JoinConfirmation bool  `json:"join_confirmation"`
+func RegisterPartyRoutes(r *mux.Router) {
+	party.Subscribe(notifyParty)
+
+	base := "/party/api/v1/{namespace}"
+	r.HandleFunc(base+"/parties", CreateParty).Methods("POST")
+	r.HandleFunc(base+"/parties/{partyId}", GetParty).Methods("GET")
+	r.HandleFunc(base+"/parties/{partyId}", PatchParty).Methods("PATCH")
+	r.HandleFunc(base+"/parties/{partyId}", DeleteParty).Methods("DELETE")
+	r.HandleFunc(base+"/parties/{partyId}/members/{accountId}/meta", PatchMemberMeta).Methods("PATCH")
+	r.HandleFunc(base+"/parties/{partyId}/members/{accountId}/join", JoinParty).Methods("POST")
+	r.HandleFunc(base+"/parties/{partyId}/members/{accountId}/promote", PromoteMember).Methods("POST")
+	r.HandleFunc(base+"/parties/{partyId}/members/{accountId}/confirm", ForbiddenHandler).Methods("POST")
+	r.HandleFunc(base+"/parties/{partyId}/members/{accountId}", DeleteMember).Methods("DELETE")
+	r.HandleFunc(base+"/parties/{partyId}/invites/{accountId}", SendPartyInvite).Methods("POST")
+	r.HandleFunc(base+"/parties/{partyId}/invites/{accountId}", CancelPartyInvite).Methods("DELETE")
+	r.HandleFunc(base+"/parties/{partyId}/invites/{accountId}/decline", DeclinePartyInvite).Methods("POST")
+	r.HandleFunc(base+"/members/{accountId}/intentions", ListIntentions).Methods("GET")
+	r.HandleFunc(base+"/members/{accountId}/intentions/{senderId}", PostIntention).Methods("POST")
+	r.HandleFunc(base+"/members/{accountId}/intentions/{senderId}", DeleteIntention).Methods("DELETE")
+	r.HandleFunc(base+"/user/{accountId}/pings", ListUserPings).Methods("GET")
+	r.HandleFunc(base+"/user/{accountId}/pings/{pingerId}", PostUserPing).Methods("POST")
+	r.HandleFunc(base+"/user/{accountId}/pings/{pingerId}", DeleteUserPing).Methods("DELETE")
+	r.HandleFunc(base+"/user/{accountId}/pings/{pingerId}/parties", GetPingerParty).Methods("GET")
+	r.HandleFunc(base+"/user/{accountId}/notifications/undelivered/count", UndeliveredCount).Methods("GET")
+	r.HandleFunc(base+"/user/{accountId}", GetUserParty).Methods("GET")
+	r.HandleFunc(base+"/{any:.*}", EmptyListHandler)
 }
 
-type PartyResponse struct {
-	ID         string      `json:"id"`
-	CreatedAt  time.Time   `json:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at"`
-	Config     PartyConfig `json:"config"`
-	Members    []Member    `json:"members"`
-	Applicants []string    `json:"applicants"`
-	Meta       interface{} `json:"meta"`
-	Invites    []string    `json:"invites"`
-	Revision   int         `json:"revision"`
+func sendPartyError(w http.ResponseWriter, err error) {
+	switch err {
+	case party.ErrPartyNotFound:
+		structs.SendDetailedError(w, structs.Errors["party_not_found"], http.StatusNotFound)
+	case party.ErrMemberNotFound:
+		structs.SendDetailedError(w, structs.Errors["member_not_found"], http.StatusNotFound)
+	case party.ErrPartyFull:
+		structs.SendDetailedError(w, structs.Errors["party_full"], http.StatusConflict)
+	case party.ErrStaleRevision:
+		structs.SendDetailedError(w, structs.Errors["stale_revision"], http.StatusConflict)
+	case party.ErrForbidden:
+		structs.SendDetailedError(w, structs.Errors["party_forbidden"], http.StatusForbidden)
+	case party.ErrNotInvited:
+		structs.SendDetailedError(w, structs.Errors["party_join_forbidden"], http.StatusForbidden)
+	case party.ErrNotFound:
+		structs.SendDetailedError(w, structs.Errors["not_found"], http.StatusNotFound)
+	default:
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With(err.Error()), http.StatusBadRequest)
+	}
 }
 
-type JoinInfoConnection struct {
-	ID   string                 `json:"id"`
-	Meta map[string]interface{} `json:"meta"`
+func CreateParty(w http.ResponseWriter, r *http.Request) {
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	var req PartyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if req.JoinInfo.Connection.ID == "" {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("join_info.connection.id"), http.StatusBadRequest)
+		return
+	}
+	if req.JoinInfo.AccountID() != caller {
+		sendPartyError(w, party.ErrForbidden)
+		return
+	}
+
+	p := party.Create(req.Config, req.Meta, req.JoinInfo)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
 }
 
-type JoinInfo struct {
-	Connection JoinInfoConnection `json:"connection"`
+func GetParty(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireCaller(w, r); !ok {
+		return
+	}
+	p := party.Get(mux.Vars(r)["partyId"])
+	if p == nil {
+		sendPartyError(w, party.ErrPartyNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
 }
 
-type PartyRequest struct {
-	Config   PartyConfig `json:"config"`
-	Meta     interface{} `json:"meta"`
-	JoinInfo JoinInfo    `json:"join_info"`
+func PatchParty(w http.ResponseWriter, r *http.Request) {
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	var req PartyPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+
+	_, err := party.UpdateParty(mux.Vars(r)["partyId"], caller, req.Revision, req.Config, req.Meta.Update, req.Meta.Delete)
+	if err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func RegisterRoutes(r *mux.Router) {
-	base := "/party/api/v1"
-	r.HandleFunc(base+"/{any:.*}/parties", CreateParty).Methods("POST")
-	r.HandleFunc(base+"/{any:.*}/parties/{partyId}/members/{accountId}/meta", PatchMemberMeta).Methods("PATCH")
-	r.HandleFunc(base+"/{any:.*}/user/{accountId}/pings/{pingerId}", PostUserPing).Methods("POST")
-	r.HandleFunc(base+"/{any:.*}/parties/{partyId}/members/{accountId}/promote", EmptyHandler).Methods("POST")
-	r.HandleFunc(base+"/{any:.*}/parties/{partyId}/members/{accountId}/confirm", ForbiddenHandler).Methods("POST")
-	r.HandleFunc(base+"/{any:.*}/parties/{partyId}/members/{accountId}", DeleteMember).Methods("DELETE")
-	r.HandleFunc(base+"/{any:.*}/user/{accountId}", GetUserParty).Methods("GET")
-	r.HandleFunc(base+"/{any:.*}/parties/{partyId}", GetParty).Methods("GET")
-	r.HandleFunc(base+"/{any:.*}", EmptyListHandler).Methods("ALL")
+func DeleteParty(w http.ResponseWriter, r *http.Request) {
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	if _, err := party.Disband(mux.Vars(r)["partyId"], caller); err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func CreateParty(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+func PatchMemberMeta(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["accountId"]) {
+		return
+	}
 
-	var req PartyRequest
+	var req MemberMetaPatchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		resp := PartyResponse{
-			ID:        "LobbyBotPartyLMFAO",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-			Config:    req.Config,
-			Members:   []Member{},
-			Applicants: []string{},
-			Meta:      req.Meta,
-			Invites:   []string{},
-			Revision:  0,
-		}
-		json.NewEncoder(w).Encode(resp)
-		return
-	}
-
-	accountID := ""
-	meta := MemberMeta{}
-	connections := []MemberConnection{}
-
-	if req.JoinInfo.Connection.ID != "" {
-		accountID = strings.Split(req.JoinInfo.Connection.ID, "@")[0]
-		connections = []MemberConnection{{
-			ID:          req.JoinInfo.Connection.ID,
-			ConnectedAt: time.Now(),
-			UpdatedAt:   time.Now(),
-			YieldLead:   false,
-			Meta: MemberConnectionMeta{
-				Platform: "WIN",
-				Type:     "game",
-			},
-		}}
-		meta.DisplayName = accountID
-	}
-
-	members := []Member{
-		{
-			AccountID:   accountID,
-			Meta:        meta,
-			Connections: connections,
-			Revision:    0,
-			UpdatedAt:   time.Now(),
-			JoinedAt:    time.Now(),
-			Role:        "CAPTAIN",
-		},
-	}
-
-	resp := PartyResponse{
-		ID:         "LobbyBotPartyLMFAO",
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-		Config:     req.Config,
-		Members:    members,
-		Applicants: []string{},
-		Meta:       req.Meta,
-		Invites:    []string{},
-		Revision:   0,
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
 	}
 
-	json.NewEncoder(w).Encode(resp)
+	if _, err := party.UpdateMember(vars["partyId"], vars["accountId"], req.Revision, req.Update, req.Delete); err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func PatchMemberMeta(w http.ResponseWriter, r *http.Request) {
+func JoinParty(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["accountId"]) {
+		return
+	}
+
+	var join party.JoinInfo
+	if err := json.NewDecoder(r.Body).Decode(&join); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+	if join.Connection.ID == "" {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("connection.id"), http.StatusBadRequest)
+		return
+	}
+	if join.AccountID() != vars["accountId"] {
+		sendPartyError(w, party.ErrForbidden)
+		return
+	}
+
+	p, err := party.Join(vars["partyId"], vars["accountId"], join)
+	if err != nil {
+		sendPartyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "JOINED",
+		"party_id": p.ID,
+	})
+}
+
+func PromoteMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	if _, err := party.Promote(vars["partyId"], caller, vars["accountId"]); err != nil {
+		sendPartyError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func PostUserPing(w http.ResponseWriter, r *http.Request) {
+func DeleteMember(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	accountId := vars["accountId"]
-	pingerId := vars["pingerId"]
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	if _, err := party.Leave(vars["partyId"], caller, vars["accountId"]); err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	resp := map[string]interface{}{
-		"sent_by":   pingerId,
-		"sent_to":   accountId,
-		"sent_at":   time.Now(),
-		"expires_at": time.Now().Add(1 * time.Hour),
-		"meta":      map[string]interface{}{},
+// readMeta decodes an optional meta object body; an empty body is fine.
+func readMeta(r *http.Request) map[string]string {
+	meta := map[string]string{}
+	json.NewDecoder(r.Body).Decode(&meta)
+	return meta
+}
+
+func SendPartyInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	inv, err := party.SendInvite(vars["partyId"], caller, vars["accountId"], readMeta(r))
+	if err != nil {
+		sendPartyError(w, err)
+		return
 	}
 
-	json.NewEncoder(w).Encode(resp)
+	if r.URL.Query().Get("sendPing") == "true" {
+		party.SendPing(vars["accountId"], inv.SentBy, inv.Meta)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
+func CancelPartyInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	caller, ok := requireCaller(w, r)
+	if !ok {
+		return
+	}
+	if p := party.Get(vars["partyId"]); p != nil && p.Member(caller) == nil {
+		sendPartyError(w, party.ErrForbidden)
+		return
+	}
+	if _, err := party.CancelInvite(vars["partyId"], vars["accountId"]); err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func GetUserParty(w http.ResponseWriter, r *http.Request) {
+func DeclinePartyInvite(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	accountId := vars["accountId"]
+	if !requireAccount(w, r, vars["accountId"]) {
+		return
+	}
+	if _, err := party.DeclineInvite(vars["partyId"], vars["accountId"]); err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	resp := map[string]interface{}{
-		"current": []PartyResponse{
-			{
-				ID:        "LobbyBotPartyLMFAO",
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-				Config: PartyConfig{
-					Type:             "DEFAULT",
-					Joinability:      "INVITE_AND_FORMER",
-					Discoverability:  "INVITED_ONLY",
-					SubType:          "default",
-					MaxSize:          16,
-					InviteTTL:        14400,
-					JoinConfirmation: true,
-				},
-				Members: []Member{
-					{
-						AccountID: accountId,
-						Meta: MemberMeta{
-							DisplayName: accountId,
-						},
-						Connections: []MemberConnection{
-							{
-								ID:          "",
-								ConnectedAt: time.Now(),
-								UpdatedAt:   time.Now(),
-								YieldLead:   false,
-								Meta: MemberConnectionMeta{
-									Platform: "WIN",
-									Type:     "game",
-								},
-							},
-						},
-						Revision:  0,
-						UpdatedAt: time.Now(),
-						JoinedAt:  time.Now(),
-						Role:      "CAPTAIN",
-					},
-				},
-				Applicants: []string{},
-				Meta:       map[string]interface{}{},
-				Invites:    []string{},
-				Revision:   0,
-			},
-		},
-		"pending": []interface{}{},
-		"invites": []interface{}{},
-		"pings":   []interface{}{},
+func PostIntention(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	senderId := vars["senderId"]
+	if !requireAccount(w, r, senderId) {
+		return
 	}
 
-	json.NewEncoder(w).Encode(resp)
+	displayName := senderId
+	if a := account.Get(senderId); a != nil {
+		displayName = a.DisplayName
+	}
+
+	in, err := party.SendIntention(party.Intention{
+		RequesterID:   senderId,
+		RequesterDN:   displayName,
+		RequesterPl:   "win",
+		RequesterPlDN: displayName,
+		RequesteeID:   vars["accountId"],
+		Meta:          readMeta(r),
+	})
+	if err != nil {
+		sendPartyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(in)
 }
 
-func GetParty(w http.ResponseWriter, r *http.Request) {
+func DeleteIntention(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["accountId"], vars["senderId"]) {
+		return
+	}
+	if _, err := party.RemoveIntention(vars["accountId"], vars["senderId"]); err != nil {
+		sendPartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	resp := PartyResponse{
-		ID:        vars["partyId"],
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Config: PartyConfig{
-			Type:             "DEFAULT",
-			Joinability:      "OPEN",
-			Discoverability:  "ALL",
-			SubType:          "default",
-			MaxSize:          16,
-			InviteTTL:        14400,
-			JoinConfirmation: false,
-		},
-		Members:    []Member{},
-		Applicants: []string{},
-		Meta:       map[string]interface{}{},
-		Invites:    []string{},
-		Revision:   0,
+func ListIntentions(w http.ResponseWriter, r *http.Request) {
+	if !requireAccount(w, r, mux.Vars(r)["accountId"]) {
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(party.Intentions(mux.Vars(r)["accountId"], false))
+}
 
-	json.NewEncoder(w).Encode(resp)
+func PostUserPing(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["pingerId"]) {
+		return
+	}
+	ping := party.SendPing(vars["accountId"], vars["pingerId"], readMeta(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ping)
 }
 
-func DeleteMember(w http.ResponseWriter, r *http.Request) {
+func DeleteUserPing(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["accountId"], vars["pingerId"]) {
+		return
+	}
+	if _, err := party.RemovePing(vars["accountId"], vars["pingerId"]); err != nil {
+		sendPartyError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func ListUserPings(w http.ResponseWriter, r *http.Request) {
+	if !requireAccount(w, r, mux.Vars(r)["accountId"]) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(party.UserPings(mux.Vars(r)["accountId"]))
+}
+
+func GetPingerParty(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !requireAccount(w, r, vars["accountId"]) {
+		return
+	}
+	p, err := party.PingerParty(vars["accountId"], vars["pingerId"])
+	if err != nil {
+		sendPartyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode([]*party.Party{p})
+}
+
+func UndeliveredCount(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+	if !requireAccount(w, r, accountId) {
+		return
+	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pings":   len(party.UserPings(accountId)),
+		"invites": len(party.UserInvites(accountId)),
+	})
+}
+
+func GetUserParty(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+	if !requireAccount(w, r, accountId) {
+		return
+	}
+
+	current := []*party.Party{}
+	if p := party.GetUserParty(accountId); p != nil {
+		current = append(current, p)
+	}
+
+	resp := map[string]interface{}{
+		"current": current,
+		"pending": party.Intentions(accountId, true),
+		"invites": party.UserInvites(accountId),
+		"pings":   party.UserPings(accountId),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
 func EmptyHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
@@ -274,3 +429,184 @@ func EmptyListHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte("[]"))
 }
+
+const partyNotificationPrefix = "com.epicgames.social.party.notification.v0."
+
+func displayNameOf(accountId string, meta map[string]string) string {
+	if dn := meta["urn:epic:member:dn_s"]; dn != "" {
+		return dn
+	}
+	if a := account.Get(accountId); a != nil {
+		return a.DisplayName
+	}
+	return accountId
+}
+
+func nonNilMeta(meta map[string]string) map[string]string {
+	if meta == nil {
+		return map[string]string{}
+	}
+	return meta
+}
+
+func nonNilKeys(keys []string) []string {
+	if keys == nil {
+		return []string{}
+	}
+	return keys
+}
+
+// notifyParty turns a party state change into the XMPP notification real
+// clients expect and sends it to every member of the party, plus whoever the
+// change is about when they are no longer part of it.
+func notifyParty(e party.Event) {
+	sent := time.Now().UTC()
+	body := map[string]interface{}{
+		"sent": sent,
+		"type": partyNotificationPrefix + e.Type,
+		"ns":   "Fortnite",
+	}
+	if e.Party != nil {
+		body["party_id"] = e.Party.ID
+	}
+
+	var recipients []string
+	addRecipient := func(accountId string) {
+		for _, existing := range recipients {
+			if existing == accountId {
+				return
+			}
+		}
+		recipients = append(recipients, accountId)
+	}
+	if e.Party != nil {
+		for _, m := range e.Party.Members {
+			addRecipient(m.AccountID)
+		}
+	}
+
+	switch e.Type {
+	case party.EventMemberJoined:
+		m := e.Member
+		if len(m.Connections) > 0 {
+			body["connection"] = m.Connections[0]
+		}
+		body["revision"] = m.Revision
+		body["account_id"] = m.AccountID
+		body["account_dn"] = displayNameOf(m.AccountID, m.Meta)
+		body["member_state_updated"] = m.Meta
+		body["joined_at"] = m.JoinedAt
+		body["updated_at"] = m.UpdatedAt
+
+	case party.EventMemberLeft, party.EventMemberKicked:
+		m := e.Member
+		body["revision"] = m.Revision
+		body["account_id"] = m.AccountID
+		body["member_state_updated"] = map[string]string{}
+		addRecipient(m.AccountID)
+
+	case party.EventMemberNewCaptain:
+		m := e.Member
+		body["revision"] = m.Revision
+		body["account_id"] = m.AccountID
+		body["account_dn"] = displayNameOf(m.AccountID, m.Meta)
+		body["member_state_updated"] = map[string]string{}
+		body["joined_at"] = m.JoinedAt
+		body["updated_at"] = m.UpdatedAt
+
+	case party.EventMemberStateUpdated:
+		m := e.Member
+		body["revision"] = m.Revision
+		body["account_id"] = m.AccountID
+		body["account_dn"] = displayNameOf(m.AccountID, m.Meta)
+		body["member_state_removed"] = nonNilKeys(e.Removed)
+		body["member_state_updated"] = nonNilMeta(e.Updated)
+		body["joined_at"] = m.JoinedAt
+		body["updated_at"] = m.UpdatedAt
+
+	case party.EventPartyUpdated:
+		p := e.Party
+		if c := p.Captain(); c != nil {
+			body["captain_id"] = c.AccountID
+		}
+		body["revision"] = p.Revision
+		body["party_state_removed"] = nonNilKeys(e.Removed)
+		body["party_state_updated"] = nonNilMeta(e.Updated)
+		body["party_privacy_type"] = p.Config.Joinability
+		body["party_type"] = p.Config.Type
+		body["party_sub_type"] = p.Config.SubType
+		body["max_number_of_members"] = p.Config.MaxSize
+		body["invite_ttl_seconds"] = p.Config.InviteTTL
+		body["created_at"] = p.CreatedAt
+		body["updated_at"] = p.UpdatedAt
+
+	case party.EventInitialInvite:
+		inv := e.Invite
+		inviterDN := displayNameOf(inv.SentBy, inv.Meta)
+		if m := e.Party.Member(inv.SentBy); m != nil {
+			inviterDN = displayNameOf(inv.SentBy, m.Meta)
+		}
+		body["inviter_id"] = inv.SentBy
+		body["inviter_dn"] = inviterDN
+		body["inviter_pl"] = "win"
+		body["inviter_pl_dn"] = inviterDN
+		body["invitee_id"] = inv.SentTo
+		body["meta"] = nonNilMeta(inv.Meta)
+		body["members_count"] = len(e.Party.Members)
+		body["sent_at"] = inv.SentAt
+		body["updated_at"] = inv.UpdatedAt
+		body["expires"] = inv.ExpiresAt
+		recipients = []string{inv.SentTo}
+
+	case party.EventInviteCancelled:
+		inv := e.Invite
+		body["inviter_id"] = inv.SentBy
+		body["inviter_dn"] = displayNameOf(inv.SentBy, nil)
+		body["invitee_id"] = inv.SentTo
+		body["cancelled_at"] = sent
+		addRecipient(inv.SentTo)
+
+	case party.EventInviteDeclined:
+		inv := e.Invite
+		inviteeDN := displayNameOf(inv.SentTo, nil)
+		body["inviter_id"] = inv.SentBy
+		body["invitee_id"] = inv.SentTo
+		body["invitee_dn"] = inviteeDN
+		body["invitee_pl"] = "win"
+		body["invitee_pl_dn"] = inviteeDN
+		body["sent_at"] = inv.SentAt
+		body["updated_at"] = sent
+
+	case party.EventPing:
+		ping := e.Ping
+		pingerDN := displayNameOf(ping.SentBy, nil)
+		delete(body, "party_id")
+		body["pinger_id"] = ping.SentBy
+		body["pinger_dn"] = pingerDN
+		body["pinger_pl"] = "win"
+		body["pinger_pl_dn"] = pingerDN
+		body["expires"] = ping.ExpiresAt
+		body["meta"] = nonNilMeta(ping.Meta)
+		recipients = []string{ping.SentTo}
+
+	case party.EventInitialIntention:
+		in := e.Intention
+		delete(body, "party_id")
+		body["requester_id"] = in.RequesterID
+		body["requester_dn"] = in.RequesterDN
+		body["requester_pl"] = in.RequesterPl
+		body["requester_pl_dn"] = in.RequesterPlDN
+		body["requestee_id"] = in.RequesteeID
+		body["meta"] = nonNilMeta(in.Meta)
+		body["expires_at"] = in.ExpiresAt
+		body["sent_at"] = in.SentAt
+		recipients = []string{in.RequesteeID}
+
+	default:
+		return
+	}
+
+	for _, accountId := range recipients {
+		xmpp.SendMessage(accountId, body)
+	}
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func createTestParty(t *testing.T, r http.Handler, token, accountId string) string {
+	t.Helper()
+	w := call(r, "POST", "/party/api/v1/Fortnite/parties", token,
+		`{"join_info":{"connection":{"id":"`+accountId+`@prod.ol.epicgames.com/V2:Fortnite"}}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create party: %d %s", w.Code, w.Body)
+	}
+	var p struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &p)
+	return p.ID
+}
+
+func TestPartyRequiresCaller(t *testing.T) {
+	r := testRouter(RegisterPartyRoutes)
+	captain := login("party-captain")
+	member := login("party-member")
+	partyId := createTestParty(t, r, captain, "party-captain")
+	if w := call(r, "POST", "/party/api/v1/Fortnite/parties/"+partyId+"/invites/party-member", captain, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("invite: %d %s", w.Code, w.Body)
+	}
+	if w := call(r, "POST", "/party/api/v1/Fortnite/parties/"+partyId+"/members/party-member/join", member,
+		`{"connection":{"id":"party-member@prod.ol.epicgames.com/V2:Fortnite"}}`); w.Code != http.StatusOK {
+		t.Fatalf("join: %d %s", w.Code, w.Body)
+	}
+
+	base := "/party/api/v1/Fortnite/parties/" + partyId
+	tests := []struct {
+		name, method, path, token, body string
+		want                            int
+	}{
+		{"create without token", "POST", "/party/api/v1/Fortnite/parties", "", `{"join_info":{"connection":{"id":"x@prod.ol.epicgames.com"}}}`, http.StatusUnauthorized},
+		{"create for someone else", "POST", "/party/api/v1/Fortnite/parties", member, `{"join_info":{"connection":{"id":"x@prod.ol.epicgames.com"}}}`, http.StatusForbidden},
+		{"patch without token", "PATCH", base, "", `{"revision":0}`, http.StatusUnauthorized},
+		{"patch with unknown token", "PATCH", base, "not-a-token", `{"revision":0}`, http.StatusUnauthorized},
+		{"patch by member", "PATCH", base, member, `{"revision":0}`, http.StatusForbidden},
+		{"disband without token", "DELETE", base, "", "", http.StatusUnauthorized},
+		{"disband by member", "DELETE", base, member, "", http.StatusForbidden},
+		{"promote without token", "POST", base + "/members/party-member/promote", "", "", http.StatusUnauthorized},
+		{"promote by member", "POST", base + "/members/party-member/promote", member, "", http.StatusForbidden},
+		{"kick without token", "DELETE", base + "/members/party-member", "", "", http.StatusUnauthorized},
+		{"kick by member", "DELETE", base + "/members/party-captain", member, "", http.StatusForbidden},
+		{"member meta without token", "PATCH", base + "/members/party-member/meta", "", `{"revision":0}`, http.StatusUnauthorized},
+		{"member meta of someone else", "PATCH", base + "/members/party-member/meta", captain, `{"revision":0}`, http.StatusForbidden},
+		{"join without token", "POST", base + "/members/someone/join", "", `{}`, http.StatusUnauthorized},
+		{"join as someone else", "POST", base + "/members/someone/join", member, `{}`, http.StatusForbidden},
+		{"join with someone else's connection", "POST", base + "/members/party-member/join", member, `{"connection":{"id":"party-captain@prod.ol.epicgames.com/V2:Fortnite"}}`, http.StatusForbidden},
+		{"invite without token", "POST", base + "/invites/someone", "", "", http.StatusUnauthorized},
+		{"user party of someone else", "GET", "/party/api/v1/Fortnite/user/party-captain", member, "", http.StatusForbidden},
+		{"ping as someone else", "POST", "/party/api/v1/Fortnite/user/party-captain/pings/someone", member, "", http.StatusForbidden},
+		{"promote by captain", "POST", base + "/members/party-member/promote", captain, "", http.StatusNoContent},
+		{"kick by new captain", "DELETE", base + "/members/party-captain", member, "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := call(r, tt.method, tt.path, tt.token, tt.body); w.Code != tt.want {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"neonite-go/routes/xmpp"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterPresenceRoutes(r *mux.Router) {
+	r.HandleFunc("/presence/api/v1/{accountId}/last", accountOnly(LastPresenceHandler)).Methods("GET")
+	r.HandleFunc("/presence/api/v1/_/{accountId}/last-online", accountOnly(LastOnlineHandler)).Methods("GET")
+}
+
+// LastPresenceHandler exposes the last presence an account published over
+// XMPP, with the status blob decoded when it is JSON.
+func LastPresenceHandler(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+	rec, ok := xmpp.LastPresence(accountId)
+	if !ok {
+		structs.SendDetailedError(w, structs.Errors["not_found"].With(accountId), http.StatusNotFound)
+		return
+	}
+
+	var status interface{} = rec.Status
+	var decoded map[string]interface{}
+	if json.Unmarshal([]byte(rec.Status), &decoded) == nil {
+		status = decoded
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accountId":  rec.AccountID,
+		"online":     rec.Online,
+		"jid":        rec.JID,
+		"status":     status,
+		"show":       rec.Show,
+		"updated":    rec.Updated,
+		"lastOnline": rec.LastOnline,
+	})
+}
+
+func LastOnlineHandler(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+
+	response := map[string]interface{}{}
+	if rec, ok := xmpp.LastPresence(accountId); ok {
+		response[accountId] = []map[string]interface{}{
+			{"last_online": rec.LastOnline},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPresenceRequiresOwner(t *testing.T) {
+	r := testRouter(RegisterPresenceRoutes)
+	owner := login("presence-owner")
+	other := login("presence-other")
+
+	tests := []struct {
+		name, path, token string
+		want              int
+	}{
+		{"last without token", "/presence/api/v1/presence-owner/last", "", http.StatusUnauthorized},
+		{"last of someone else", "/presence/api/v1/presence-owner/last", other, http.StatusForbidden},
+		{"last of self", "/presence/api/v1/presence-owner/last", owner, http.StatusNotFound},
+		{"last online without token", "/presence/api/v1/_/presence-owner/last-online", "", http.StatusUnauthorized},
+		{"last online of someone else", "/presence/api/v1/_/presence-owner/last-online", other, http.StatusForbidden},
+		{"last online of self", "/presence/api/v1/_/presence-owner/last-online", owner, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := call(r, "GET", tt.path, tt.token, ""); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
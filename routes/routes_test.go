@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"neonite-go/account"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMain runs the tests in a scratch directory, since the stores write
+// under config/.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "neonite-routes")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testRouter registers the given route sets on a fresh router.
+func testRouter(register ...func(*mux.Router)) *mux.Router {
+	r := mux.NewRouter()
+	for _, fn := range register {
+		fn(r)
+	}
+	return r
+}
+
+// login returns an access token for the account.
+func login(accountId string) string {
+	token := account.NewToken()
+	account.StoreToken(token, accountId, account.AccessTokenLifetime)
+	return token
+}
+
+// call sends a request through the router, with the bearer token when it is
+// not empty.
+func call(r http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"neonite-go/account"
+	"neonite-go/friends"
+	"neonite-go/structs"
+
+	"github.com/gorilla/mux"
+)
+
+const searchResultLimit = 100
+
+func RegisterSearchRoutes(r *mux.Router) {
+	r.HandleFunc("/api/v1/search/{accountId}", accountOnly(UserSearchHandler)).Methods("GET")
+}
+
+type searchMatch struct {
+	Value    string `json:"value"`
+	Platform string `json:"platform"`
+}
+
+type searchResult struct {
+	AccountID    string        `json:"accountId"`
+	Matches      []searchMatch `json:"matches"`
+	MatchType    string        `json:"matchType"`
+	EpicMutuals  int           `json:"epicMutuals"`
+	SortPosition int           `json:"sortPosition"`
+}
+
+func UserSearchHandler(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["accountId"]
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	platform := r.URL.Query().Get("platform")
+	if platform == "" {
+		platform = "epic"
+	}
+
+	if prefix == "" {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("prefix"), http.StatusBadRequest)
+		return
+	}
+
+	results := []searchResult{}
+	if platform == "epic" {
+		for i, m := range account.Search(prefix, accountId, searchResultLimit, func(id string) bool {
+			return friends.IsFriend(accountId, id)
+		}) {
+			matchType := "prefix"
+			if m.Exact {
+				matchType = "exact"
+			}
+			results = append(results, searchResult{
+				AccountID:    m.Account.ID,
+				Matches:      []searchMatch{{Value: m.Account.DisplayName, Platform: platform}},
+				MatchType:    matchType,
+				EpicMutuals:  0,
+				SortPosition: i,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
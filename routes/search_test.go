@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"neonite-go/account"
+)
+
+func TestUserSearch(t *testing.T) {
+	r := testRouter(RegisterSearchRoutes)
+	for id, name := range map[string]string{"search-me": "SearchMe", "search-neo": "neo", "search-neon": "NeonCat"} {
+		account.Record(id, name)
+	}
+	me := login("search-me")
+
+	tests := []struct {
+		name, path, token string
+		want              int
+	}{
+		{"without token", "/api/v1/search/search-me?prefix=neo", "", http.StatusUnauthorized},
+		{"as someone else", "/api/v1/search/search-neo?prefix=neo", me, http.StatusForbidden},
+		{"without prefix", "/api/v1/search/search-me", me, http.StatusBadRequest},
+		{"as self", "/api/v1/search/search-me?prefix=neo", me, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := call(r, "GET", tt.path, tt.token, ""); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	w := call(r, "GET", "/api/v1/search/search-me?prefix=neo", me, "")
+	var results []searchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].AccountID != "search-neo" || results[0].MatchType != "exact" || results[1].MatchType != "prefix" {
+		t.Errorf("results = %+v", results)
+	}
+}
@@ -1,35 +1,212 @@
 package routes
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
+
+	"neonite-go/keychain"
+	"neonite-go/shop"
+	"neonite-go/structs"
 
 	"github.com/gorilla/mux"
 )
 
 func RegisterStorefrontRoutes(r *mux.Router) {
-
+	r.HandleFunc("/fortnite/api/storefront/v2/catalog", CatalogHandler).Methods("GET")
 	r.HandleFunc("/fortnite/api/storefront/v2/keychain", KeychainHandler).Methods("GET")
+	r.HandleFunc("/api/v1/admin/keychain", AddKeychainHandler).Methods("POST")
+}
+
+const storeTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// catalogShape is how a build expects the catalog laid out. The offers are
+// the same for every build; only where they go and how they are tagged
+// changes.
+type catalogShape struct {
+	// sections tags entries with the shop section they belong in (8.50+).
+	sections bool
+	// newDisplayAssets adds the NewDisplayAssetPath the tiles use for their
+	// art (14.40+).
+	newDisplayAssets bool
+	// layouts puts every offer in the weekly storefront and lets the
+	// section and layout ids lay out the shop (20.00+).
+	layouts bool
+	// wideTiles allows the DoubleWide tiles used for bundles (8.50+).
+	wideTiles bool
 }
 
+// catalogShapeFor picks the shape for a build. Clients that do not send a
+// game User-Agent get the newest shape.
+func catalogShapeFor(b structs.BuildInfo) catalogShape {
+	if !b.Known {
+		b = structs.BuildInfo{Major: 99}
+	}
+	return catalogShape{
+		sections:         b.AtLeast(8, 50),
+		newDisplayAssets: b.AtLeast(14, 40),
+		layouts:          b.AtLeast(20, 0),
+		wideTiles:        b.AtLeast(8, 50),
+	}
+}
+
+func catalogEntry(o shop.Offer, priority int, shape catalogShape) map[string]interface{} {
+	grants := make([]map[string]interface{}, 0, len(o.Items))
+	requirements := make([]map[string]interface{}, 0, len(o.Items))
+	for _, templateId := range o.TemplateIDs() {
+		grants = append(grants, map[string]interface{}{"templateId": templateId, "quantity": 1})
+		requirements = append(requirements, map[string]interface{}{
+			"requirementType": "DenyOnItemOwnership",
+			"requiredId":      templateId,
+			"minQuantity":     1,
+		})
+	}
+
+	devName := fmt.Sprintf("[VIRTUAL]1 x %s for %d MtxCurrency", o.Items[0].Name, o.Price)
+	tileSize := "Small"
+	if o.Section == shop.SectionFeatured {
+		tileSize = "Normal"
+	}
+	if o.IsBundle() {
+		devName = fmt.Sprintf("[VIRTUAL]%s for %d MtxCurrency", o.Name, o.Price)
+		if shape.wideTiles {
+			tileSize = "DoubleWide"
+		}
+	}
+
+	meta := map[string]interface{}{"TileSize": tileSize}
+	if shape.sections {
+		meta["SectionId"] = o.Section
+	}
+	if shape.newDisplayAssets && !o.IsBundle() {
+		asset := "DAv2_" + o.Items[0].ID
+		meta["NewDisplayAssetPath"] = "/Game/Catalog/NewDisplayAssets/" + asset + "." + asset
+	}
+	if shape.layouts {
+		meta["LayoutId"] = o.Section + ".99"
+		meta["AnalyticOfferGroupId"] = o.Section
+	}
+	metaInfo := make([]map[string]interface{}, 0, len(meta))
+	for _, key := range []string{"NewDisplayAssetPath", "SectionId", "LayoutId", "AnalyticOfferGroupId", "TileSize"} {
+		if v, ok := meta[key]; ok {
+			metaInfo = append(metaInfo, map[string]interface{}{"key": key, "value": v})
+		}
+	}
+
+	return map[string]interface{}{
+		"devName":        devName,
+		"offerId":        o.ID,
+		"fulfillmentIds": []interface{}{},
+		"dailyLimit":     -1,
+		"weeklyLimit":    -1,
+		"monthlyLimit":   -1,
+		"categories":     []interface{}{},
+		"prices": []map[string]interface{}{{
+			"currencyType":        "MtxCurrency",
+			"currencySubType":     "",
+			"regularPrice":        o.Regular,
+			"dynamicRegularPrice": o.Regular,
+			"finalPrice":          o.Price,
+			"saleExpiration":      "9999-12-31T23:59:59.999Z",
+			"basePrice":           o.Price,
+		}},
+		"meta":         meta,
+		"matchFilter":  "",
+		"filterWeight": 0,
+		"appStoreId":   []interface{}{},
+		"requirements": requirements,
+		"offerType":    "StaticPrice",
+		"giftInfo": map[string]interface{}{
+			"bIsEnabled":              true,
+			"forcedGiftBoxTemplateId": "",
+			"purchaseRequirements":    []interface{}{},
+			"giftRecordIds":           []interface{}{},
+		},
+		"refundable":           !o.IsBundle(),
+		"metaInfo":             metaInfo,
+		"displayAssetPath":     "",
+		"itemGrants":           grants,
+		"sortPriority":         priority,
+		"catalogGroupPriority": 0,
+	}
+}
+
+func storefront(name string, offers []shop.Offer, shape catalogShape) map[string]interface{} {
+	entries := make([]map[string]interface{}, 0, len(offers))
+	for i, o := range offers {
+		// Earlier offers sort first.
+		entries = append(entries, catalogEntry(o, len(offers)-i, shape))
+	}
+	return map[string]interface{}{"name": name, "catalogEntries": entries}
+}
+
+// CatalogHandler serves the item shop generated for the current rotation,
+// shaped for the requesting build.
 func CatalogHandler(w http.ResponseWriter, r *http.Request) {
-	file, err := os.ReadFile("shop.json")
-	if err != nil {
-		http.Error(w, `{"error":"Failed to read shop.json"}`, http.StatusInternalServerError)
-		return
+	rot := shop.Current()
+	shape := catalogShapeFor(structs.GetBuildInfo(r))
+
+	weekly, daily := rot.Featured, rot.Daily
+	if shape.layouts {
+		weekly, daily = append(append([]shop.Offer{}, weekly...), daily...), nil
+	}
+
+	resp := map[string]interface{}{
+		"refreshIntervalHrs": 24,
+		"dailyPurchaseHrs":   24,
+		"expiration":         rot.DailyEnd.Format(storeTimeFormat),
+		"storefronts": []map[string]interface{}{
+			storefront("BRWeeklyStorefront", weekly, shape),
+			storefront("BRDailyStorefront", daily, shape),
+		},
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(file)
+	json.NewEncoder(w).Encode(resp)
 }
 
+// KeychainHandler lists the AES keys for the requesting build, or an empty
+// list when there are none.
 func KeychainHandler(w http.ResponseWriter, r *http.Request) {
-	file, err := os.ReadFile("keychain.json") // keychain download :3
-	if err != nil {
-		http.Error(w, `{"error":"Failed to read keychain.json"}`, http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keychain.For(structs.GetBuildInfo(r)))
+}
+
+// AddKeychainHandler adds a key to the keychain. The body is
+// {"key": "guid:base64key:templateId", "from": "14.40", "until": "14.60"}
+// with the build range optional. It answers 201 for a new key and 200 for
+// one already there.
+func AddKeychainHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		Key   string `json:"key"`
+		From  string `json:"from"`
+		Until string `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With("invalid JSON"), http.StatusBadRequest)
+		return
+	}
+
+	entry, added, err := keychain.Add(body.Key, body.From, body.Until)
+	switch {
+	case errors.Is(err, keychain.ErrConflict):
+		structs.SendDetailedError(w, structs.Errors["keychain_conflict"].With(err.Error()), http.StatusConflict)
+		return
+	case errors.Is(err, keychain.ErrInvalidEntry), errors.Is(err, keychain.ErrInvalidVersion):
+		structs.SendDetailedError(w, structs.Errors["invalid_request"].With(err.Error()), http.StatusBadRequest)
+		return
+	case err != nil:
+		structs.SendDetailedError(w, structs.Errors["server_error"].With(err.Error()), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(file)
+	if added {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"key": entry.String(), "added": added})
 }
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"neonite-go/shop"
+)
+
+func TestTimelineStoreEnds(t *testing.T) {
+	r := testRouter(RegisterTimelineRoutes)
+	w := call(r, "GET", "/fortnite/api/calendar/v1/timeline", "", "")
+
+	var resp struct {
+		Channels struct {
+			ClientEvents struct {
+				States []struct {
+					State map[string]interface{} `json:"state"`
+				} `json:"states"`
+			} `json:"client-events"`
+		} `json:"channels"`
+		CurrentTime string `json:"currentTime"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if !strings.HasSuffix(resp.CurrentTime, "Z") {
+		t.Errorf("currentTime %q is not UTC", resp.CurrentTime)
+	}
+
+	// The bundled calendar leaves the store ends to the shop.
+	rot := shop.Current()
+	states := resp.Channels.ClientEvents.States
+	if len(states) == 0 {
+		t.Fatal("no client-events states")
+	}
+	if got, want := states[0].State["dailyStoreEnd"], rot.DailyEnd.Format(storeTimeFormat); got != want {
+		t.Errorf("dailyStoreEnd = %v, want %v", got, want)
+	}
+	if got, want := states[0].State["weeklyStoreEnd"], rot.FeaturedEnd.Format(storeTimeFormat); got != want {
+		t.Errorf("weeklyStoreEnd = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package xmpp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Element is a parsed XMPP stanza. Names keep their prefix (for example
+// "stream:features") so stanzas can be written back out unchanged.
+type Element struct {
+	Name     string
+	Attrs    []xml.Attr
+	Children []*Element
+	Text     string
+}
+
+func rawName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// ParseElement parses a single complete stanza, as sent in one WebSocket
+// frame.
+func ParseElement(data []byte) (*Element, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return readElement(d, start)
+		}
+	}
+}
+
+// readElement reads the rest of an element whose start tag was already
+// consumed.
+func readElement(d *xml.Decoder, start xml.StartElement) (*Element, error) {
+	e := &Element{Name: rawName(start.Name), Attrs: start.Attr}
+	var text strings.Builder
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := readElement(d, t)
+			if err != nil {
+				return nil, err
+			}
+			e.Children = append(e.Children, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			e.Text = text.String()
+			return e, nil
+		}
+	}
+}
+
+// Local returns the element name without its prefix.
+func (e *Element) Local() string {
+	if i := strings.IndexByte(e.Name, ':'); i >= 0 {
+		return e.Name[i+1:]
+	}
+	return e.Name
+}
+
+func (e *Element) Attr(name string) string {
+	for _, a := range e.Attrs {
+		if rawName(a.Name) == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (e *Element) SetAttr(name, value string) {
+	for i, a := range e.Attrs {
+		if rawName(a.Name) == name {
+			e.Attrs[i].Value = value
+			return
+		}
+	}
+	e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (e *Element) RemoveAttr(name string) {
+	for i, a := range e.Attrs {
+		if rawName(a.Name) == name {
+			e.Attrs = append(e.Attrs[:i], e.Attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Child returns the first child with the given local name, or nil.
+func (e *Element) Child(local string) *Element {
+	for _, c := range e.Children {
+		if c.Local() == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// ChildText returns the text of the first matching child, or "".
+func (e *Element) ChildText(local string) string {
+	if c := e.Child(local); c != nil {
+		return c.Text
+	}
+	return ""
+}
+
+func (e *Element) String() string {
+	var b strings.Builder
+	e.write(&b)
+	return b.String()
+}
+
+func (e *Element) write(b *strings.Builder) {
+	b.WriteString("<" + e.Name)
+	for _, a := range e.Attrs {
+		b.WriteString(" " + rawName(a.Name) + `="` + Escape(a.Value) + `"`)
+	}
+	if len(e.Children) == 0 && e.Text == "" {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	b.WriteString(EscapeText(e.Text))
+	for _, c := range e.Children {
+		c.write(b)
+	}
+	b.WriteString("</" + e.Name + ">")
+}
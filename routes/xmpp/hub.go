@@ -0,0 +1,128 @@
+package xmpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"neonite-go/structs"
+)
+
+const (
+	Domain   = "prod.ol.epicgames.com"
+	AdminJID = "xmpp-admin@" + Domain
+)
+
+// Conn is an authenticated XMPP session that stanzas can be delivered to.
+type Conn interface {
+	AccountID() string
+	JID() string
+	Send(stanza string) error
+}
+
+var (
+	hubMu sync.RWMutex
+	conns = make(map[string][]Conn)
+)
+
+// Register makes a session reachable through SendMessage and friends.
+func Register(c Conn) {
+	hubMu.Lock()
+	defer hubMu.Unlock()
+	conns[c.AccountID()] = append(conns[c.AccountID()], c)
+}
+
+func Unregister(c Conn) {
+	hubMu.Lock()
+	defer hubMu.Unlock()
+
+	list := conns[c.AccountID()]
+	for i, other := range list {
+		if other == c {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(conns, c.AccountID())
+	} else {
+		conns[c.AccountID()] = list
+	}
+}
+
+// Sessions returns the connected sessions of an account.
+func Sessions(accountId string) []Conn {
+	hubMu.RLock()
+	defer hubMu.RUnlock()
+	return append([]Conn{}, conns[accountId]...)
+}
+
+func IsConnected(accountId string) bool {
+	return len(Sessions(accountId)) > 0
+}
+
+var (
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// Escape makes s safe to use as an attribute value.
+func Escape(s string) string {
+	return attrEscaper.Replace(s)
+}
+
+// EscapeText makes s safe to use as element text, leaving quotes readable
+// so JSON bodies stay as the client expects them.
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// SendMessage delivers a JSON notification body from the admin JID to every
+// session of the account, the way Epic's services push friend and party events.
+func SendMessage(accountId string, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		structs.NeoLog("Failed to encode XMPP message: " + err.Error())
+		return
+	}
+
+	for _, c := range Sessions(accountId) {
+		stanza := fmt.Sprintf(`<message xmlns="jabber:client" from="%s" to="%s"><body>%s</body></message>`,
+			AdminJID, Escape(c.JID()), EscapeText(string(data)))
+		if err := c.Send(stanza); err != nil {
+			structs.NeoLog("Failed to send XMPP message to " + c.JID() + ": " + err.Error())
+		}
+	}
+}
+
+// BareJID strips the resource from a JID.
+func BareJID(jid string) string {
+	if i := strings.IndexByte(jid, '/'); i >= 0 {
+		return jid[:i]
+	}
+	return jid
+}
+
+// AccountOf returns the account part of a JID.
+func AccountOf(jid string) string {
+	if i := strings.IndexByte(jid, '@'); i >= 0 {
+		return jid[:i]
+	}
+	return BareJID(jid)
+}
+
+// Route delivers a stanza to a full JID, or to every session of a bare JID.
+func Route(to string, e *Element) {
+	full := strings.Contains(to, "/")
+	if e.Attr("xmlns") == "" {
+		e.SetAttr("xmlns", "jabber:client")
+	}
+	for _, c := range Sessions(AccountOf(to)) {
+		if full && c.JID() != to {
+			continue
+		}
+		e.SetAttr("to", c.JID())
+		c.Send(e.String())
+	}
+}
@@ -0,0 +1,264 @@
+package xmpp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"neonite-go/party"
+)
+
+const (
+	MucDomain = "muc." + Domain
+
+	nsMUC     = "http://jabber.org/protocol/muc"
+	nsMUCUser = "http://jabber.org/protocol/muc#user"
+)
+
+type occupant struct {
+	conn     Conn
+	nick     string
+	presence *Element
+}
+
+type room struct {
+	jid       string
+	occupants []*occupant
+}
+
+var (
+	roomsMu sync.Mutex
+	rooms   = make(map[string]*room)
+
+	watchOnce sync.Once
+)
+
+// WatchParties ties party rooms to party membership, so members that leave
+// or get kicked are dropped from the party's chat room. main starts it once,
+// whichever listeners come up.
+func WatchParties() {
+	watchOnce.Do(func() {
+		party.Subscribe(func(e party.Event) {
+			if e.Party == nil || e.Member == nil {
+				return
+			}
+			if e.Type == party.EventMemberLeft || e.Type == party.EventMemberKicked {
+				removeFromRoom(PartyRoomJID(e.Party.ID), e.Member.AccountID)
+			}
+		})
+	})
+}
+
+// PartyRoomJID is the bare JID of a party's chat room.
+func PartyRoomJID(partyId string) string {
+	return "Party-" + partyId + "@" + MucDomain
+}
+
+func isMucJID(jid string) bool {
+	return strings.HasSuffix(BareJID(jid), "@"+MucDomain)
+}
+
+// mayJoinRoom keeps party rooms restricted to the party's members.
+func mayJoinRoom(roomJID, accountId string) bool {
+	name := AccountOf(roomJID)
+	if !strings.HasPrefix(name, "Party-") {
+		return true
+	}
+	p := party.Get(strings.TrimPrefix(name, "Party-"))
+	return p != nil && p.Member(accountId) != nil
+}
+
+func (r *room) find(c Conn) *occupant {
+	for _, o := range r.occupants {
+		if o.conn == c {
+			return o
+		}
+	}
+	return nil
+}
+
+// occupantPresence renders an occupant's presence as seen by the recipient.
+func (r *room) occupantPresence(o *occupant, to Conn, unavailable bool) string {
+	e := &Element{Name: "presence"}
+	if o.presence != nil && !unavailable {
+		copied := *o.presence
+		e = &copied
+		e.Attrs = nil
+		e.Children = nil
+		for _, c := range o.presence.Children {
+			if c.Local() != "x" {
+				e.Children = append(e.Children, c)
+			}
+		}
+	}
+	e.SetAttr("xmlns", "jabber:client")
+	e.SetAttr("from", r.jid+"/"+o.nick)
+	e.SetAttr("to", to.JID())
+	if unavailable {
+		e.SetAttr("type", "unavailable")
+	}
+
+	x := &Element{Name: "x"}
+	x.SetAttr("xmlns", nsMUCUser)
+	item := &Element{Name: "item"}
+	item.SetAttr("nick", o.nick)
+	item.SetAttr("jid", o.conn.JID())
+	item.SetAttr("affiliation", "none")
+	item.SetAttr("role", "participant")
+	if unavailable {
+		item.SetAttr("role", "none")
+	}
+	x.Children = append(x.Children, item)
+	if o.conn == to {
+		status := &Element{Name: "status"}
+		status.SetAttr("code", "110")
+		x.Children = append(x.Children, status)
+	}
+	e.Children = append(e.Children, x)
+	return e.String()
+}
+
+func roomError(c Conn, e *Element, errType, condition string) {
+	c.Send(fmt.Sprintf(`<presence xmlns="jabber:client" type="error" from="%s" to="%s"><error type="%s"><%s xmlns="%s"/></error></presence>`,
+		Escape(e.Attr("to")), Escape(c.JID()), errType, condition, nsStanzas))
+}
+
+// handleRoomPresence joins, updates or leaves a room depending on the
+// presence sent to room@muc/nick.
+func handleRoomPresence(c Conn, e *Element) {
+	to := e.Attr("to")
+	roomJID := BareJID(to)
+	nick := strings.TrimPrefix(to, roomJID+"/")
+
+	if e.Attr("type") == "unavailable" {
+		leaveRoom(roomJID, c)
+		return
+	}
+	if nick == "" || nick == to {
+		roomError(c, e, "modify", "jid-malformed")
+		return
+	}
+	if !mayJoinRoom(roomJID, c.AccountID()) {
+		roomError(c, e, "auth", "forbidden")
+		return
+	}
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	r := rooms[roomJID]
+	if r == nil {
+		r = &room{jid: roomJID}
+		rooms[roomJID] = r
+	}
+
+	o := r.find(c)
+	joined := o == nil
+	if joined {
+		for _, other := range r.occupants {
+			if other.nick == nick {
+				roomError(c, e, "cancel", "conflict")
+				return
+			}
+		}
+		o = &occupant{conn: c, nick: nick}
+		r.occupants = append(r.occupants, o)
+	}
+	o.presence = e
+
+	if joined {
+		for _, other := range r.occupants {
+			if other != o {
+				c.Send(r.occupantPresence(other, c, false))
+			}
+		}
+	}
+	for _, other := range r.occupants {
+		other.conn.Send(r.occupantPresence(o, other.conn, false))
+	}
+}
+
+// leaveRoom removes a connection from a room and tells the others.
+// Callers must not hold roomsMu.
+func leaveRoom(roomJID string, c Conn) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	leaveRoomLocked(rooms[roomJID], c)
+}
+
+func leaveRoomLocked(r *room, c Conn) {
+	if r == nil {
+		return
+	}
+	o := r.find(c)
+	if o == nil {
+		return
+	}
+
+	for _, other := range r.occupants {
+		other.conn.Send(r.occupantPresence(o, other.conn, true))
+	}
+	for i, other := range r.occupants {
+		if other == o {
+			r.occupants = append(r.occupants[:i], r.occupants[i+1:]...)
+			break
+		}
+	}
+	if len(r.occupants) == 0 {
+		delete(rooms, r.jid)
+	}
+}
+
+// removeFromRoom drops every connection of an account from a room.
+func removeFromRoom(roomJID, accountId string) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	r := rooms[roomJID]
+	if r == nil {
+		return
+	}
+	for _, o := range append([]*occupant{}, r.occupants...) {
+		if o.conn.AccountID() == accountId {
+			leaveRoomLocked(r, o.conn)
+		}
+	}
+}
+
+// leaveAllRooms is called when a connection goes away.
+func leaveAllRooms(c Conn) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	for _, r := range rooms {
+		leaveRoomLocked(r, c)
+	}
+}
+
+// handleGroupchat broadcasts a message to every occupant of the room the
+// sender is in.
+func handleGroupchat(c Conn, e *Element) {
+	roomJID := BareJID(e.Attr("to"))
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	r := rooms[roomJID]
+	if r == nil {
+		return
+	}
+	o := r.find(c)
+	if o == nil {
+		c.Send(fmt.Sprintf(`<message xmlns="jabber:client" type="error" from="%s" to="%s"><error type="cancel"><not-acceptable xmlns="%s"/></error></message>`,
+			Escape(roomJID), Escape(c.JID()), nsStanzas))
+		return
+	}
+
+	e.SetAttr("xmlns", "jabber:client")
+	e.SetAttr("from", roomJID+"/"+o.nick)
+	e.SetAttr("type", "groupchat")
+	for _, other := range r.occupants {
+		e.SetAttr("to", other.conn.JID())
+		other.conn.Send(e.String())
+	}
+}
@@ -0,0 +1,192 @@
+package xmpp
+
+import (
+	"sync"
+	"time"
+
+	"neonite-go/friends"
+)
+
+// PresenceRecord is the last presence an account published.
+type PresenceRecord struct {
+	AccountID  string    `json:"accountId"`
+	Online     bool      `json:"online"`
+	JID        string    `json:"jid"`
+	Status     string    `json:"status"`
+	Show       string    `json:"show"`
+	Updated    time.Time `json:"updated"`
+	LastOnline time.Time `json:"lastOnline"`
+}
+
+var (
+	presenceMu   sync.RWMutex
+	lastPresence = make(map[string]*PresenceRecord)
+)
+
+// LastPresence returns the latest presence seen for an account, including
+// after it went offline.
+func LastPresence(accountId string) (PresenceRecord, bool) {
+	presenceMu.RLock()
+	defer presenceMu.RUnlock()
+
+	rec, ok := lastPresence[accountId]
+	if !ok {
+		return PresenceRecord{}, false
+	}
+	return *rec, true
+}
+
+func recordPresence(c Conn, e *Element, online bool) {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	now := time.Now().UTC()
+	rec := lastPresence[c.AccountID()]
+	if rec == nil {
+		rec = &PresenceRecord{AccountID: c.AccountID()}
+		lastPresence[c.AccountID()] = rec
+	}
+	rec.Online = online
+	rec.JID = c.JID()
+	rec.Updated = now
+	rec.LastOnline = now
+	if e != nil {
+		rec.Status = e.ChildText("status")
+		rec.Show = e.ChildText("show")
+	}
+}
+
+// SetPresence records a presence published outside XMPP, such as through
+// the EOS presence service, keeping the session's JID if there is one, and
+// broadcasts it like an XMPP presence.
+func SetPresence(accountId, status, show string, online bool) PresenceRecord {
+	presenceMu.Lock()
+	now := time.Now().UTC()
+	rec := lastPresence[accountId]
+	if rec == nil {
+		rec = &PresenceRecord{AccountID: accountId}
+		lastPresence[accountId] = rec
+	}
+	rec.Online = online
+	rec.Status = status
+	rec.Show = show
+	rec.Updated = now
+	rec.LastOnline = now
+	set := *rec
+	presenceMu.Unlock()
+
+	e := &Element{Name: "presence"}
+	if online {
+		if show != "" {
+			e.Children = append(e.Children, &Element{Name: "show", Text: show})
+		}
+		e.Children = append(e.Children, &Element{Name: "status", Text: status})
+	} else {
+		e.SetAttr("type", "unavailable")
+	}
+	// Friends see the presence from each XMPP session, or from the bare JID
+	// when the account has none.
+	from := Sessions(accountId)
+	if len(from) == 0 {
+		from = []Conn{accountConn(accountId)}
+	}
+	for _, c := range from {
+		broadcastPresence(c, e)
+	}
+	return set
+}
+
+// accountConn stands in for an account without an XMPP session.
+type accountConn string
+
+func (a accountConn) AccountID() string { return string(a) }
+func (a accountConn) JID() string       { return string(a) + "@" + Domain }
+func (a accountConn) Send(string) error { return nil }
+
+func acceptedFriends(accountId string) []string {
+	var ids []string
+	for _, f := range friends.Get(accountId).Filter(friends.StatusAccepted, "") {
+		ids = append(ids, f.AccountID)
+	}
+	return ids
+}
+
+// presenceFor copies a presence stanza as sent from one connection to
+// another.
+func presenceFor(e *Element, from, to Conn) string {
+	copied := *e
+	copied.Attrs = append(copied.Attrs[:0:0], e.Attrs...)
+	copied.RemoveAttr("to")
+	copied.SetAttr("xmlns", "jabber:client")
+	copied.SetAttr("from", from.JID())
+	copied.SetAttr("to", to.JID())
+	return copied.String()
+}
+
+func unavailablePresence() *Element {
+	e := &Element{Name: "presence"}
+	e.SetAttr("type", "unavailable")
+	return e
+}
+
+// broadcastPresence sends a connection's presence to its own sessions and to
+// every session of its accepted friends.
+func broadcastPresence(from Conn, e *Element) {
+	targets := append([]string{from.AccountID()}, acceptedFriends(from.AccountID())...)
+	for _, accountId := range targets {
+		for _, c := range Sessions(accountId) {
+			c.Send(presenceFor(e, from, c))
+		}
+	}
+}
+
+// sendFriendPresences tells a newly available connection what its friends
+// are currently doing.
+func sendFriendPresences(to Conn) {
+	for _, accountId := range acceptedFriends(to.AccountID()) {
+		for _, c := range Sessions(accountId) {
+			if p := currentPresence(c); p != nil {
+				to.Send(presenceFor(p, c, to))
+			}
+		}
+	}
+}
+
+// ExchangePresence lets two accounts that just became friends see each
+// other without waiting for the next presence change.
+func ExchangePresence(a, b string) {
+	for _, ca := range Sessions(a) {
+		for _, cb := range Sessions(b) {
+			if p := currentPresence(ca); p != nil {
+				cb.Send(presenceFor(p, ca, cb))
+			}
+			if p := currentPresence(cb); p != nil {
+				ca.Send(presenceFor(p, cb, ca))
+			}
+		}
+	}
+}
+
+// WithdrawPresence shows two accounts that are no longer friends as offline
+// to each other.
+func WithdrawPresence(a, b string) {
+	for _, ca := range Sessions(a) {
+		for _, cb := range Sessions(b) {
+			cb.Send(presenceFor(unavailablePresence(), ca, cb))
+			ca.Send(presenceFor(unavailablePresence(), cb, ca))
+		}
+	}
+}
+
+// presenceHolder is implemented by connections that remember their last
+// broadcast presence.
+type presenceHolder interface {
+	Presence() *Element
+}
+
+func currentPresence(c Conn) *Element {
+	if h, ok := c.(presenceHolder); ok {
+		return h.Presence()
+	}
+	return nil
+}
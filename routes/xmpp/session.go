@@ -0,0 +1,379 @@
+package xmpp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"neonite-go/account"
+	"neonite-go/structs"
+
+	"github.com/google/uuid"
+)
+
+const (
+	nsStreams = "http://etherx.jabber.org/streams"
+	nsSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsSession = "urn:ietf:params:xml:ns:xmpp-session"
+	nsStanzas = "urn:ietf:params:xml:ns:xmpp-stanzas"
+	nsTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+)
+
+// transport is the framing a session runs over.
+type transport interface {
+	// openStream answers the client's stream header.
+	openStream(id string) error
+	// closeStream ends the stream and drops the connection.
+	closeStream()
+	write(data string) error
+}
+
+// tlsUpgrader is implemented by transports that must switch to TLS before
+// the client may authenticate.
+type tlsUpgrader interface {
+	needsTLS() bool
+}
+
+func (s *session) needsTLS() bool {
+	u, ok := s.t.(tlsUpgrader)
+	return ok && u.needsTLS()
+}
+
+// session is one client connection, independent of how it is framed.
+type session struct {
+	t  transport
+	id string
+
+	mu            sync.Mutex
+	authenticated bool
+	bound         bool
+	accountId     string
+	resource      string
+	jid           string
+	closed        bool
+	presence      *Element
+}
+
+func newSession(t transport) *session {
+	return &session{t: t, id: uuid.New().String()}
+}
+
+func (s *session) AccountID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accountId
+}
+
+func (s *session) JID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jid
+}
+
+// Presence returns the last available presence the session broadcast.
+func (s *session) Presence() *Element {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.presence
+}
+
+func (s *session) Send(stanza string) error {
+	return s.t.write(stanza)
+}
+
+func (s *session) streamError(condition string) {
+	s.Send(fmt.Sprintf(`<stream:error xmlns:stream="%s"><%s xmlns="urn:ietf:params:xml:ns:xmpp-streams"/></stream:error>`, nsStreams, condition))
+	s.t.closeStream()
+}
+
+// features advertises STARTTLS, then SASL before authentication and binding
+// after it.
+func (s *session) features() string {
+	s.mu.Lock()
+	authenticated := s.authenticated
+	s.mu.Unlock()
+
+	if s.needsTLS() {
+		return fmt.Sprintf(`<stream:features xmlns:stream="%s">`+
+			`<starttls xmlns="%s"><required/></starttls>`+
+			`</stream:features>`, nsStreams, nsTLS)
+	}
+	if !authenticated {
+		return fmt.Sprintf(`<stream:features xmlns:stream="%s">`+
+			`<mechanisms xmlns="%s"><mechanism>PLAIN</mechanism></mechanisms>`+
+			`<ver xmlns="urn:xmpp:features:rosterver"/>`+
+			`<auth xmlns="http://jabber.org/features/iq-auth"/>`+
+			`</stream:features>`, nsStreams, nsSASL)
+	}
+	return fmt.Sprintf(`<stream:features xmlns:stream="%s">`+
+		`<ver xmlns="urn:xmpp:features:rosterver"/>`+
+		`<bind xmlns="%s"/>`+
+		`<session xmlns="%s"/>`+
+		`</stream:features>`, nsStreams, nsBind, nsSession)
+}
+
+// open answers a (re)started stream with the current features.
+func (s *session) open() {
+	if err := s.t.openStream(s.id); err == nil {
+		s.Send(s.features())
+	}
+}
+
+// handle processes one top level element from the client.
+func (s *session) handle(e *Element) {
+	if s.needsTLS() && e.Local() != "open" && e.Local() != "close" {
+		s.streamError("policy-violation")
+		return
+	}
+
+	switch e.Local() {
+	case "open":
+		s.open()
+	case "auth":
+		s.handleSASL(e)
+	case "iq":
+		s.handleIQ(e)
+	case "presence":
+		if s.isBound() {
+			s.handlePresence(e)
+		}
+	case "message":
+		if s.isBound() {
+			s.handleMessage(e)
+		}
+	case "close":
+		s.t.closeStream()
+	}
+}
+
+func (s *session) isBound() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bound
+}
+
+// checkToken verifies an account and access token pair against the token
+// store.
+func checkToken(accountId, token string) bool {
+	owner, ok := account.TokenOwner(token)
+	return ok && accountId != "" && owner == accountId
+}
+
+func (s *session) handleSASL(e *Element) {
+	fail := func() {
+		s.Send(fmt.Sprintf(`<failure xmlns="%s"><not-authorized/></failure>`, nsSASL))
+	}
+
+	// A session signs in once; it cannot switch accounts afterwards.
+	s.mu.Lock()
+	signedIn := s.authenticated || s.bound
+	s.mu.Unlock()
+	if signedIn {
+		fail()
+		return
+	}
+
+	if e.Attr("mechanism") != "" && e.Attr("mechanism") != "PLAIN" {
+		s.Send(fmt.Sprintf(`<failure xmlns="%s"><invalid-mechanism/></failure>`, nsSASL))
+		return
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(e.Text))
+	if err != nil {
+		fail()
+		return
+	}
+	// authzid \0 authcid \0 password
+	parts := strings.Split(string(decoded), "\x00")
+	if len(parts) != 3 || !checkToken(parts[1], parts[2]) {
+		fail()
+		return
+	}
+
+	s.mu.Lock()
+	s.authenticated = true
+	s.accountId = parts[1]
+	s.mu.Unlock()
+
+	s.Send(fmt.Sprintf(`<success xmlns="%s"/>`, nsSASL))
+}
+
+func (s *session) iqResult(e *Element, payload string) {
+	s.Send(fmt.Sprintf(`<iq xmlns="jabber:client" type="result" id="%s" from="%s" to="%s">%s</iq>`,
+		Escape(e.Attr("id")), Domain, Escape(s.JID()), payload))
+}
+
+func (s *session) iqError(e *Element, errType, condition string) {
+	s.Send(fmt.Sprintf(`<iq xmlns="jabber:client" type="error" id="%s" from="%s"><error type="%s"><%s xmlns="%s"/></error></iq>`,
+		Escape(e.Attr("id")), Domain, errType, condition, nsStanzas))
+}
+
+func (s *session) handleIQ(e *Element) {
+	// Older builds authenticate with jabber:iq:auth instead of SASL.
+	if q := e.Child("query"); q != nil && q.Attr("xmlns") == "jabber:iq:auth" {
+		s.handleLegacyAuth(e, q)
+		return
+	}
+
+	s.mu.Lock()
+	authenticated := s.authenticated
+	s.mu.Unlock()
+	iqType := e.Attr("type")
+	answers := iqType == "result" || iqType == "error"
+	if !authenticated {
+		if !answers {
+			s.iqError(e, "auth", "not-authorized")
+		}
+		return
+	}
+
+	// IQs addressed to another client go to it; its answer comes back the
+	// same way.
+	if to := e.Attr("to"); s.isBound() && to != "" && !s.servedHere(to) {
+		if !answers && !IsConnected(AccountOf(to)) {
+			s.iqError(e, "cancel", "service-unavailable")
+			return
+		}
+		e.SetAttr("from", s.JID())
+		Route(to, e)
+		return
+	}
+	// Results and errors are never answered.
+	if answers {
+		return
+	}
+
+	switch {
+	case e.Child("bind") != nil:
+		resource := strings.TrimSpace(e.Child("bind").ChildText("resource"))
+		if resource == "" {
+			resource = uuid.New().String()
+		}
+		s.bind(resource)
+		s.iqResult(e, fmt.Sprintf(`<bind xmlns="%s"><jid>%s</jid></bind>`, nsBind, EscapeText(s.JID())))
+	case e.Child("session") != nil, e.Child("ping") != nil:
+		s.iqResult(e, "")
+	case e.Child("query") != nil && e.Child("query").Attr("xmlns") == "jabber:iq:roster":
+		s.iqResult(e, `<query xmlns="jabber:iq:roster"/>`)
+	default:
+		s.iqResult(e, "")
+	}
+}
+
+// servedHere reports whether the server answers stanzas sent to jid: its
+// own domain, the MUC service and the session's own account.
+func (s *session) servedHere(jid string) bool {
+	bare := BareJID(jid)
+	return bare == Domain || bare == MucDomain || isMucJID(jid) || bare == BareJID(s.JID())
+}
+
+func (s *session) handleLegacyAuth(e *Element, q *Element) {
+	s.mu.Lock()
+	signedIn := s.authenticated || s.bound
+	s.mu.Unlock()
+	if signedIn {
+		s.iqError(e, "cancel", "not-allowed")
+		return
+	}
+
+	accountId := q.ChildText("username")
+	resource := q.ChildText("resource")
+	if resource == "" || !checkToken(accountId, q.ChildText("password")) {
+		s.iqError(e, "auth", "not-authorized")
+		return
+	}
+
+	s.mu.Lock()
+	s.authenticated = true
+	s.accountId = accountId
+	s.mu.Unlock()
+
+	s.bind(resource)
+	s.iqResult(e, "")
+}
+
+// bind assigns the session its full JID and makes it reachable.
+func (s *session) bind(resource string) {
+	s.mu.Lock()
+	wasBound := s.bound
+	s.resource = resource
+	s.jid = fmt.Sprintf("%s@%s/%s", s.accountId, Domain, resource)
+	s.bound = true
+	s.mu.Unlock()
+
+	if !wasBound {
+		Register(s)
+		structs.NeoLog("[XMPP] " + s.JID() + " connected")
+	}
+}
+
+// disconnect runs once the transport is gone.
+func (s *session) disconnect() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	bound := s.bound
+	wasAvailable := s.presence != nil
+	s.presence = nil
+	s.mu.Unlock()
+
+	if bound {
+		leaveAllRooms(s)
+		Unregister(s)
+		if wasAvailable {
+			broadcastPresence(s, unavailablePresence())
+			recordPresence(s, nil, IsConnected(s.AccountID()))
+		}
+		structs.NeoLog("[XMPP] " + s.JID() + " disconnected")
+	}
+}
+
+// handlePresence delivers directed presence to its target and broadcasts
+// everything else to the account's own sessions and its friends.
+func (s *session) handlePresence(e *Element) {
+	if to := e.Attr("to"); isMucJID(to) {
+		handleRoomPresence(s, e)
+		return
+	}
+
+	e.SetAttr("from", s.JID())
+	if to := e.Attr("to"); to != "" {
+		Route(to, e)
+		return
+	}
+
+	available := e.Attr("type") != "unavailable"
+
+	s.mu.Lock()
+	first := s.presence == nil && available
+	if available {
+		s.presence = e
+	} else {
+		s.presence = nil
+	}
+	s.mu.Unlock()
+
+	broadcastPresence(s, e)
+	recordPresence(s, e, available || len(Sessions(s.AccountID())) > 1)
+	if first {
+		sendFriendPresences(s)
+	}
+}
+
+func (s *session) handleMessage(e *Element) {
+	to := e.Attr("to")
+	if to == "" {
+		return
+	}
+	if isMucJID(to) {
+		handleGroupchat(s, e)
+		return
+	}
+	e.SetAttr("from", s.JID())
+	Route(to, e)
+}
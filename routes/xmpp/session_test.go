@@ -0,0 +1,138 @@
+package xmpp
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"neonite-go/account"
+)
+
+// TestMain runs the tests in a scratch directory, since the token store
+// writes under config/.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "neonite-xmpp")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// recordingTransport keeps what a session writes.
+type recordingTransport struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (t *recordingTransport) openStream(id string) error { return nil }
+func (t *recordingTransport) closeStream()               {}
+
+func (t *recordingTransport) write(data string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes = append(t.writes, data)
+	return nil
+}
+
+func (t *recordingTransport) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.writes
+	t.writes = nil
+	return w
+}
+
+// boundSession returns a session signed in and bound as the account.
+func boundSession(t *testing.T, accountId string) (*session, *recordingTransport) {
+	t.Helper()
+	tr := &recordingTransport{}
+	s := newSession(tr)
+	s.authenticated = true
+	s.accountId = accountId
+	s.bind("V2:Fortnite:WIN")
+	t.Cleanup(func() { Unregister(s) })
+	tr.take()
+	return s, tr
+}
+
+func handleString(t *testing.T, s *session, stanza string) {
+	t.Helper()
+	e, err := ParseElement([]byte(stanza))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handle(e)
+}
+
+func TestHandleIQ(t *testing.T) {
+	alice, aliceOut := boundSession(t, "iq-alice")
+	_, bobOut := boundSession(t, "iq-bob")
+	bobJID := "iq-bob@" + Domain + "/V2:Fortnite:WIN"
+
+	tests := []struct {
+		name    string
+		stanza  string
+		toAlice string // substring of the one stanza alice gets, "" for none
+		toBob   string // substring of the one stanza bob gets, "" for none
+	}{
+		{"ping is answered", `<iq type="get" id="p1"><ping xmlns="urn:xmpp:ping"/></iq>`, `type="result"`, ""},
+		{"result is not answered", `<iq type="result" id="r1"/>`, "", ""},
+		{"error is not answered", `<iq type="error" id="e1"><error type="cancel"/></iq>`, "", ""},
+		{"result to the server is not answered", `<iq type="result" id="r2" to="` + Domain + `"/>`, "", ""},
+		{"get to a client is routed", `<iq type="get" id="g1" to="` + bobJID + `"><query xmlns="jabber:iq:version"/></iq>`, "", `from="iq-alice@`},
+		{"result to a client is routed", `<iq type="result" id="g2" to="` + bobJID + `"/>`, "", `id="g2"`},
+		{"get to an offline client fails", `<iq type="get" id="g3" to="iq-nobody@` + Domain + `/x"/>`, `service-unavailable`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handleString(t, alice, tt.stanza)
+			for _, c := range []struct {
+				who  string
+				out  []string
+				want string
+			}{{"alice", aliceOut.take(), tt.toAlice}, {"bob", bobOut.take(), tt.toBob}} {
+				if c.want == "" {
+					if len(c.out) != 0 {
+						t.Errorf("%s got %v, want nothing", c.who, c.out)
+					}
+					continue
+				}
+				if len(c.out) != 1 || !strings.Contains(c.out[0], c.want) {
+					t.Errorf("%s got %v, want one stanza with %s", c.who, c.out, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSignInOnce(t *testing.T) {
+	s, out := boundSession(t, "auth-alice")
+	token := account.NewToken()
+	account.StoreToken(token, "auth-mallory", account.AccessTokenLifetime)
+	plain := base64.StdEncoding.EncodeToString([]byte("\x00auth-mallory\x00" + token))
+
+	tests := []struct {
+		name, stanza, want string
+	}{
+		{"SASL again", `<auth xmlns="` + nsSASL + `" mechanism="PLAIN">` + plain + `</auth>`, "<failure"},
+		{"legacy auth again", `<iq type="set" id="a1"><query xmlns="jabber:iq:auth"><username>auth-mallory</username>` +
+			`<password>` + token + `</password><resource>x</resource></query></iq>`, "not-allowed"},
+	}
+	for _, tt := range tests {
+		handleString(t, s, tt.stanza)
+		got := out.take()
+		if len(got) != 1 || !strings.Contains(got[0], tt.want) {
+			t.Errorf("%s: got %v, want one stanza with %s", tt.name, got, tt.want)
+		}
+		if s.AccountID() != "auth-alice" {
+			t.Fatalf("%s: session switched to %s", tt.name, s.AccountID())
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package xmpp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"neonite-go/structs"
+)
+
+// tcpTransport frames a session as a classic XMPP stream over TCP.
+type tcpTransport struct {
+	mu        sync.Mutex
+	conn      net.Conn
+	tlsActive bool
+	closed    bool
+}
+
+func (t *tcpTransport) write(data string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return net.ErrClosed
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := t.conn.Write([]byte(data))
+	return err
+}
+
+func (t *tcpTransport) openStream(id string) error {
+	return t.write(fmt.Sprintf(`<?xml version='1.0'?><stream:stream xmlns="jabber:client" xmlns:stream="%s" from="%s" id="%s" version="1.0" xml:lang="en">`, nsStreams, Domain, id))
+}
+
+func (t *tcpTransport) closeStream() {
+	t.write("</stream:stream>")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		t.conn.Close()
+	}
+}
+
+func (t *tcpTransport) needsTLS() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.tlsActive
+}
+
+// startTLS tells the client to proceed and runs the handshake. The lock is
+// held from <proceed/> until the stream is on TLS, so no other write, the
+// keepalive included, can slip plaintext into the handshake; later writes
+// go over TLS.
+func (t *tcpTransport) startTLS(config *tls.Config) (net.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, net.ErrClosed
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := t.conn.Write([]byte(fmt.Sprintf(`<proceed xmlns="%s"/>`, nsTLS))); err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Server(t.conn, config)
+	tlsConn.SetDeadline(time.Now().Add(writeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	t.conn = tlsConn
+	t.tlsActive = true
+	return tlsConn, nil
+}
+
+func serveTCP(conn net.Conn, config *tls.Config) {
+	t := &tcpTransport{conn: conn}
+	s := newSession(t)
+	defer func() {
+		s.disconnect()
+		t.mu.Lock()
+		t.closed = true
+		t.conn.Close()
+		t.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Whitespace keepalive, as allowed between stanzas.
+				if t.write(" ") != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	d := xml.NewDecoder(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		tok, err := d.RawToken()
+		if err != nil {
+			return
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if rawName(tok.Name) == "stream:stream" {
+				s.open()
+				continue
+			}
+
+			e, err := readElement(d, tok)
+			if err != nil {
+				s.streamError("not-well-formed")
+				return
+			}
+
+			if e.Local() == "starttls" {
+				if !t.needsTLS() {
+					s.streamError("policy-violation")
+					return
+				}
+				if conn, err = t.startTLS(config); err != nil {
+					return
+				}
+				// The stream restarts from scratch on top of TLS.
+				d = xml.NewDecoder(conn)
+				continue
+			}
+			s.handle(e)
+
+		case xml.EndElement:
+			t.closeStream()
+			return
+		}
+	}
+}
+
+// loadCertificate loads the configured key pair, generating and saving a
+// self-signed one the first time when the files do not exist.
+func loadCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		return cert, nil
+	} else if _, statErr := os.Stat(certFile); statErr == nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: Domain, Organization: []string{"Neonite"}},
+		DNSNames:     []string{Domain, "xmpp-service-" + Domain, "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	if err := os.MkdirAll(filepath.Dir(certFile), os.ModePerm); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), os.ModePerm); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(certFile, certPem, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(keyFile, keyPem, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	structs.NeoLog("[XMPP] Generated self-signed certificate " + certFile)
+
+	return tls.X509KeyPair(certPem, keyPem)
+}
+
+// ListenAndServeTCP runs classic XMPP client streams with STARTTLS.
+func ListenAndServeTCP(addr, certFile, keyFile string) error {
+	cert, err := loadCertificate(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	structs.NeoLog("[XMPP] Listening for TCP streams on " + addr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go serveTCP(conn, config)
+	}
+}
@@ -0,0 +1,72 @@
+package xmpp
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStartTLSWithKeepalive runs STARTTLS while keepalives are written as
+// fast as the transport allows; none may end up inside the handshake.
+func TestStartTLSWithKeepalive(t *testing.T) {
+	dir := t.TempDir()
+	cert, err := loadCertificate(filepath.Join(dir, "xmpp.crt"), filepath.Join(dir, "xmpp.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client := net.Pipe()
+	defer client.Close()
+	tr := &tcpTransport{conn: server}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if tr.write(" ") != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tr.startTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+		done <- err
+	}()
+
+	// Skip keepalives up to <proceed/>, then shake hands on what follows.
+	var seen strings.Builder
+	buf := make([]byte, 1)
+	for !strings.HasSuffix(seen.String(), "/>") {
+		if _, err := client.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		seen.Write(buf)
+	}
+	if !strings.Contains(seen.String(), "<proceed") {
+		t.Fatalf("expected <proceed/>, got %q", seen.String())
+	}
+	tlsClient := tls.Client(client, &tls.Config{InsecureSkipVerify: true})
+	go func() {
+		// Keep reading so the keepalives sent over TLS do not block.
+		b := make([]byte, 64)
+		for {
+			if _, err := tlsClient.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+	if err := tlsClient.Handshake(); err != nil {
+		t.Fatalf("client handshake: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("server handshake: %v", err)
+	}
+}
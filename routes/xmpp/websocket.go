@@ -0,0 +1,127 @@
+package xmpp
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"neonite-go/structs"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeTimeout = 10 * time.Second
+	pongTimeout  = 90 * time.Second
+	pingInterval = 30 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{"xmpp"},
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+// wsTransport frames a session per RFC 7395, one stanza per text message.
+type wsTransport struct {
+	conn *websocket.Conn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (t *wsTransport) write(data string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return websocket.ErrCloseSent
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return t.conn.WriteMessage(websocket.TextMessage, []byte(data))
+}
+
+func (t *wsTransport) openStream(id string) error {
+	return t.write(fmt.Sprintf(`<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" from="%s" id="%s" version="1.0" xml:lang="en"/>`, Domain, id))
+}
+
+func (t *wsTransport) closeStream() {
+	t.write(`<close xmlns="urn:ietf:params:xml:ns:xmpp-framing"/>`)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		t.conn.Close()
+	}
+}
+
+func (t *wsTransport) ping() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return websocket.ErrCloseSent
+	}
+	return t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+}
+
+// WebSocketHandler upgrades the request and serves an XMPP session on it.
+func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	t := &wsTransport{conn: conn}
+	s := newSession(t)
+	defer func() {
+		s.disconnect()
+		t.mu.Lock()
+		t.closed = true
+		t.mu.Unlock()
+		conn.Close()
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		return nil
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if t.ping() != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+
+		e, err := ParseElement(data)
+		if err != nil {
+			s.streamError("not-well-formed")
+			return
+		}
+		s.handle(e)
+	}
+}
+
+// ListenAndServe runs the XMPP WebSocket endpoint on its own port.
+func ListenAndServe(addr string) error {
+	structs.NeoLog("[XMPP] Listening on " + addr)
+	return http.ListenAndServe(addr, http.HandlerFunc(WebSocketHandler))
+}
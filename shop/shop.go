@@ -0,0 +1,253 @@
+package shop
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonite-go/cosmetics"
+	"neonite-go/structs"
+)
+
+const (
+	SectionFeatured = "Featured"
+	SectionDaily    = "Daily"
+)
+
+// Offer is one shop tile: a single cosmetic, or a bundle of several.
+type Offer struct {
+	ID      string
+	Name    string // bundle name, empty for single items
+	Items   []cosmetics.Cosmetic
+	Price   int
+	Regular int // what the items cost one by one
+	Section string
+}
+
+// TemplateIDs returns the template ids the offer grants.
+func (o Offer) TemplateIDs() []string {
+	ids := make([]string, len(o.Items))
+	for i, c := range o.Items {
+		ids[i] = c.TemplateID()
+	}
+	return ids
+}
+
+// IsBundle reports whether the offer grants more than one item.
+func (o Offer) IsBundle() bool {
+	return len(o.Items) > 1
+}
+
+// Rotation is the shop for one day. The featured storefront can stay up
+// for several days, so the two storefronts end separately.
+type Rotation struct {
+	Featured    []Offer
+	Daily       []Offer
+	FeaturedEnd time.Time
+	DailyEnd    time.Time
+}
+
+// Price returns what a cosmetic sells for, and false if it is not sold.
+// Prices go by backend type, since display names differ between dumps and
+// languages.
+func Price(c cosmetics.Cosmetic) (int, bool) {
+	price, ok := structs.Settings.Shop.Prices[c.Type][strings.ToLower(c.Rarity)]
+	return price, ok && price > 0
+}
+
+// resetOffset is how long after midnight UTC the shop resets.
+func resetOffset() time.Duration {
+	reset := structs.Settings.Shop.ResetTime
+	if reset == "" {
+		return 0
+	}
+	t, err := time.Parse("15:04", reset)
+	if err != nil {
+		structs.NeoLog("[Shop] Invalid resetTime " + reset + ", resetting at 00:00 UTC")
+		return 0
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// period returns the bounds of the rotation of the given length, in days,
+// that is running at t. Rotations are counted from the Unix epoch so they
+// line up across restarts.
+func period(t time.Time, days int) (time.Time, time.Time) {
+	if days < 1 {
+		days = 1
+	}
+	offset := resetOffset()
+	day := int64(t.UTC().Add(-offset).Unix() / 86400)
+	start := day - day%int64(days)
+	begin := time.Unix(start*86400, 0).UTC().Add(offset)
+	return begin, begin.AddDate(0, 0, days)
+}
+
+// rng is seeded by the section and the day its rotation began, so every
+// request on the same day sees the same shop.
+func rng(section string, begin time.Time) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(structs.Settings.Shop.Seed, 10) + ":" + section + ":" + begin.Format("2006-01-02")))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+func offerID(section string, templateIds []string) string {
+	sum := sha256.Sum256([]byte(section + ":" + strings.Join(templateIds, ",")))
+	return "v2:/" + hex.EncodeToString(sum[:])
+}
+
+func single(c cosmetics.Cosmetic, price int, section string) Offer {
+	return Offer{
+		ID:      offerID(section, []string{c.TemplateID()}),
+		Items:   []cosmetics.Cosmetic{c},
+		Price:   price,
+		Regular: price,
+		Section: section,
+	}
+}
+
+// bundle prices items together at the configured discount. It returns false
+// if fewer than two of the items are for sale.
+func bundle(name string, items []cosmetics.Cosmetic, price int, section string) (Offer, bool) {
+	o := Offer{Name: name, Section: section}
+	for _, c := range items {
+		p, ok := Price(c)
+		if !ok {
+			continue
+		}
+		o.Items = append(o.Items, c)
+		o.Regular += p
+	}
+	if len(o.Items) < 2 {
+		return Offer{}, false
+	}
+	o.Price = price
+	if o.Price <= 0 {
+		// Round down to a multiple of 100 like the real shop does.
+		o.Price = o.Regular * (100 - structs.Settings.Shop.BundleDiscount) / 100 / 100 * 100
+	}
+	o.ID = offerID(section, o.TemplateIDs())
+	return o, true
+}
+
+func pool() []cosmetics.Cosmetic {
+	var list []cosmetics.Cosmetic
+	for _, c := range cosmetics.All() {
+		if _, ok := Price(c); ok {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// sets groups the priced cosmetics by set, keeping sets of two or more.
+func sets(items []cosmetics.Cosmetic) map[string][]cosmetics.Cosmetic {
+	bySet := make(map[string][]cosmetics.Cosmetic)
+	for _, c := range items {
+		if c.Set != "" {
+			bySet[c.Set] = append(bySet[c.Set], c)
+		}
+	}
+	for name, list := range bySet {
+		if len(list) < 2 {
+			delete(bySet, name)
+		}
+	}
+	return bySet
+}
+
+// pick takes up to n offers from candidates, skipping cosmetics already
+// used. Outfits come first, as they do in the featured storefront.
+func pick(r *rand.Rand, candidates []cosmetics.Cosmetic, n int, used map[string]bool, section string, outfitsFirst bool) []Offer {
+	list := append([]cosmetics.Cosmetic{}, candidates...)
+	r.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+	if outfitsFirst {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Type == "AthenaCharacter" && list[j].Type != "AthenaCharacter"
+		})
+	}
+
+	var offers []Offer
+	for _, c := range list {
+		if len(offers) >= n {
+			break
+		}
+		if used[c.ID] {
+			continue
+		}
+		price, _ := Price(c)
+		used[c.ID] = true
+		offers = append(offers, single(c, price, section))
+	}
+	return offers
+}
+
+// generate builds the rotation running at t.
+func generate(t time.Time) Rotation {
+	cfg := structs.Settings.Shop
+	items := pool()
+	used := make(map[string]bool)
+	var rot Rotation
+
+	featuredBegin, featuredEnd := period(t, cfg.FeaturedDays)
+	dailyBegin, dailyEnd := period(t, 1)
+	rot.FeaturedEnd, rot.DailyEnd = featuredEnd, dailyEnd
+
+	for _, b := range cfg.Bundles {
+		var list []cosmetics.Cosmetic
+		for _, id := range b.Items {
+			if c, ok := cosmetics.Get(id); ok {
+				list = append(list, c)
+			}
+		}
+		if o, ok := bundle(b.Name, list, b.Price, SectionFeatured); ok {
+			rot.Featured = append(rot.Featured, o)
+		}
+	}
+
+	r := rng(SectionFeatured, featuredBegin)
+	bySet := sets(items)
+	names := make([]string, 0, len(bySet))
+	for name := range bySet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	r.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+	for _, name := range names[:min(cfg.SetBundles, len(names))] {
+		if o, ok := bundle(name+" Bundle", bySet[name], 0, SectionFeatured); ok {
+			rot.Featured = append(rot.Featured, o)
+		}
+	}
+	// Set items are also sold on their own in the featured storefront, so
+	// they are not marked used here.
+	rot.Featured = append(rot.Featured, pick(r, items, cfg.FeaturedSize, used, SectionFeatured, true)...)
+
+	// The daily storefront changes every day even when the featured one
+	// stays, so it is seeded separately and avoids what is featured.
+	rot.Daily = pick(rng(SectionDaily, dailyBegin), items, cfg.DailySize, used, SectionDaily, false)
+	return rot
+}
+
+var (
+	cacheMu sync.Mutex
+	cached  *Rotation
+)
+
+// Current returns the rotation running now. It is generated once per reset.
+func Current() Rotation {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	now := time.Now()
+	if cached == nil || !now.Before(cached.DailyEnd) {
+		rot := generate(now)
+		cached = &rot
+	}
+	return *cached
+}
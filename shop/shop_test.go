@@ -0,0 +1,27 @@
+package shop
+
+import (
+	"testing"
+
+	"neonite-go/cosmetics"
+)
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		c     cosmetics.Cosmetic
+		price int
+		ok    bool
+	}{
+		{cosmetics.Cosmetic{Type: "AthenaCharacter", DisplayType: "Outfit", Rarity: "Legendary"}, 2000, true},
+		{cosmetics.Cosmetic{Type: "AthenaPickaxe", DisplayType: "Pickaxe", Rarity: "rare"}, 800, true},
+		{cosmetics.Cosmetic{Type: "AthenaDance", DisplayType: "Emoji", Rarity: "uncommon"}, 200, true},
+		{cosmetics.Cosmetic{Type: "AthenaCharacter", DisplayType: "Outfit", Rarity: "common"}, 0, false},
+		{cosmetics.Cosmetic{Type: "AthenaLoadingScreen", DisplayType: "Loading Screen", Rarity: "rare"}, 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := Price(tt.c)
+		if price != tt.price || ok != tt.ok {
+			t.Errorf("Price(%s %s) = %d, %v, want %d, %v", tt.c.Type, tt.c.Rarity, price, ok, tt.price, tt.ok)
+		}
+	}
+}
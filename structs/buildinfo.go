@@ -0,0 +1,133 @@
+package structs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BuildInfo describes the game build that sent a request, read from its
+// User-Agent such as "Fortnite/++Fortnite+Release-14.60-CL-14786821 Windows/10".
+type BuildInfo struct {
+	Season     int
+	Major      int
+	Minor      int
+	Changelist int
+	Platform   string
+	// Known is false when the User-Agent did not look like a game build; the
+	// version then defaults to 1.0.
+	Known bool
+
+	// minorWidth is how many digits the minor was written with, so 7.01
+	// prints back as 7.01 and not 7.1.
+	minorWidth int
+}
+
+var (
+	releasePattern    = regexp.MustCompile(`Release-(\d+)\.(\d+)(?:\.\d+)?-CL-(\d+)`)
+	changelistPattern = regexp.MustCompile(`-CL-(\d+)`)
+	platformPattern   = regexp.MustCompile(`\s([A-Za-z0-9]+)/`)
+)
+
+// Changelists of the launch builds, which report "Release-Cert" or similar
+// instead of a version.
+const (
+	firstSeasonOneCL = 3724489
+	lastSeasonOneCL  = 3790078
+)
+
+// ParseBuildInfo reads the build from a User-Agent header.
+func ParseBuildInfo(userAgent string) BuildInfo {
+	b := BuildInfo{Season: 1, Major: 1}
+
+	if m := releasePattern.FindStringSubmatch(userAgent); m != nil {
+		b.Major, _ = strconv.Atoi(m[1])
+		b.Minor, _ = strconv.Atoi(m[2])
+		b.minorWidth = len(m[2])
+		b.Changelist, _ = strconv.Atoi(m[3])
+		b.Season = b.Major
+		b.Known = true
+	} else if m := changelistPattern.FindStringSubmatch(userAgent); m != nil {
+		b.Changelist, _ = strconv.Atoi(m[1])
+		b.Known = true
+		if b.Changelist < firstSeasonOneCL {
+			b.Season, b.Major = 0, 0
+		} else if b.Changelist > lastSeasonOneCL {
+			b.Known = false
+		}
+	}
+
+	if m := platformPattern.FindStringSubmatch(userAgent); m != nil {
+		b.Platform = m[1]
+	}
+	return b
+}
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)$`)
+
+// ParseVersion returns the build for a version such as "14.60", for tools
+// that take a version instead of a User-Agent.
+func ParseVersion(version string) (BuildInfo, bool) {
+	m := versionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return BuildInfo{}, false
+	}
+	b := BuildInfo{Known: true}
+	b.Major, _ = strconv.Atoi(m[1])
+	b.Minor, _ = strconv.Atoi(m[2])
+	b.minorWidth = len(m[2])
+	b.Season = b.Major
+	return b, true
+}
+
+// Version is the build's version as the game prints it, e.g. "14.60".
+func (b BuildInfo) Version() string {
+	if b.minorWidth > 0 {
+		return fmt.Sprintf("%d.%0*d", b.Major, b.minorWidth, b.Minor)
+	}
+	if b.Minor < 10 {
+		return fmt.Sprintf("%d.%d", b.Major, b.Minor)
+	}
+	return fmt.Sprintf("%d.%02d", b.Major, b.Minor)
+}
+
+// Branch names the build's release branch, e.g. "Release-14.60", or is
+// empty when the build is not known.
+func (b BuildInfo) Branch() string {
+	if !b.Known {
+		return ""
+	}
+	return "Release-" + b.Version()
+}
+
+// AtLeast reports whether the build is major.minor or newer.
+func (b BuildInfo) AtLeast(major, minor int) bool {
+	return b.Major > major || (b.Major == major && b.Minor >= minor)
+}
+
+// IsPlatform compares the platform case-insensitively.
+func (b BuildInfo) IsPlatform(platform string) bool {
+	return strings.EqualFold(b.Platform, platform)
+}
+
+type buildInfoKey struct{}
+
+// BuildInfoMiddleware parses the build once per request for handlers to
+// read with GetBuildInfo.
+func BuildInfoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := ParseBuildInfo(r.Header.Get("User-Agent"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), buildInfoKey{}, b)))
+	})
+}
+
+// GetBuildInfo returns the build that sent the request.
+func GetBuildInfo(r *http.Request) BuildInfo {
+	if b, ok := r.Context().Value(buildInfoKey{}).(BuildInfo); ok {
+		return b
+	}
+	return ParseBuildInfo(r.Header.Get("User-Agent"))
+}
@@ -0,0 +1,65 @@
+package structs
+
+import "testing"
+
+func TestParseBuildInfo(t *testing.T) {
+	tests := []struct {
+		userAgent     string
+		season, minor int
+		version       string
+		branch        string
+		known         bool
+	}{
+		{"Fortnite/++Fortnite+Release-7.01-CL-4834550 Windows/10.0.17763.1.256.64bit", 7, 1, "7.01", "Release-7.01", true},
+		{"Fortnite/++Fortnite+Release-7.10-CL-4932890 Windows/10", 7, 10, "7.10", "Release-7.10", true},
+		{"Fortnite/++Fortnite+Release-10.00-CL-8723043 Windows/10", 10, 0, "10.00", "Release-10.00", true},
+		{"Fortnite/++Fortnite+Release-12.41-CL-12905909 Windows/10", 12, 41, "12.41", "Release-12.41", true},
+		{"Fortnite/++Fortnite+Release-4.5-CL-4166199 Windows/10", 4, 5, "4.5", "Release-4.5", true},
+		{"Fortnite/++Fortnite+Release-Cert-CL-3741772 Windows/10", 1, 0, "1.0", "Release-1.0", true},
+		{"curl/8.0", 1, 0, "1.0", "", false},
+	}
+	for _, tt := range tests {
+		b := ParseBuildInfo(tt.userAgent)
+		if b.Season != tt.season || b.Minor != tt.minor || b.Known != tt.known {
+			t.Errorf("ParseBuildInfo(%q) = season %d minor %d known %v, want %d %d %v",
+				tt.userAgent, b.Season, b.Minor, b.Known, tt.season, tt.minor, tt.known)
+		}
+		if v := b.Version(); v != tt.version {
+			t.Errorf("ParseBuildInfo(%q).Version() = %q, want %q", tt.userAgent, v, tt.version)
+		}
+		if br := b.Branch(); br != tt.branch {
+			t.Errorf("ParseBuildInfo(%q).Branch() = %q, want %q", tt.userAgent, br, tt.branch)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		ok      bool
+		atLeast bool // AtLeast(7, 10)
+	}{
+		{"7.01", true, false},
+		{"7.10", true, true},
+		{"10.00", true, true},
+		{"12.41", true, true},
+		{"12", false, false},
+		{"v12.41", false, false},
+	}
+	for _, tt := range tests {
+		b, ok := ParseVersion(tt.version)
+		if ok != tt.ok {
+			t.Errorf("ParseVersion(%q) ok = %v, want %v", tt.version, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if v := b.Version(); v != tt.version {
+			t.Errorf("ParseVersion(%q).Version() = %q", tt.version, v)
+		}
+		if got := b.AtLeast(7, 10); got != tt.atLeast {
+			t.Errorf("ParseVersion(%q).AtLeast(7, 10) = %v, want %v", tt.version, got, tt.atLeast)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package structs
+
+import (
+	"encoding/json"
+	"os"
+)
+
+type Config struct {
+	Port         string            `json:"port"`
+	XmppPort     string            `json:"xmppPort"`
+	XmppTcpPort  string            `json:"xmppTcpPort"`
+	XmppCertFile string            `json:"xmppCertFile"`
+	XmppKeyFile  string            `json:"xmppKeyFile"`
+	Bot          BotConfig         `json:"bot"`
+	Shop         ShopConfig        `json:"shop"`
+	Hotfixes     HotfixConfig      `json:"hotfixes"`
+	Matchmaking  MatchmakingConfig `json:"matchmaking"`
+
+	// CloudStorageMaxSize is the largest cloudstorage file in bytes.
+	CloudStorageMaxSize int64 `json:"cloudStorageMaxSize"`
+
+	// AdminKey is the X-Admin-Key header; empty allows localhost only.
+	AdminKey string `json:"adminKey"`
+
+	// LockerCopyGrantsItems grants unowned cosmetics on a locker copy.
+	LockerCopyGrantsItems bool `json:"lockerCopyGrantsItems"`
+}
+
+// BotConfig describes the lobby bot. Cosmetics are ids without a prefix.
+type BotConfig struct {
+	DisplayName string            `json:"displayName"`
+	Status      string            `json:"status"`
+	Character   string            `json:"character"`
+	Backpack    string            `json:"backpack"`
+	Pickaxe     string            `json:"pickaxe"`
+	Level       int               `json:"level"`
+	Meta        map[string]string `json:"meta"`
+}
+
+// HotfixConfig holds the values the hotfix templates are rendered with.
+type HotfixConfig struct {
+	FrontendPlaylist  string            `json:"frontendPlaylist"`
+	EnabledPlaylists  []string          `json:"enabledPlaylists"`
+	DisabledPlaylists []string          `json:"disabledPlaylists"`
+	Features          map[string]bool   `json:"features"`
+	Vars              map[string]string `json:"vars"`
+}
+
+// MatchmakingConfig lists the game servers players are sent to. An empty
+// ServiceURL means this server's own /matchmaking endpoint.
+type MatchmakingConfig struct {
+	ServiceURL       string             `json:"serviceUrl"`
+	Servers          []GameServerConfig `json:"servers"`
+	HeartbeatTimeout int                `json:"heartbeatTimeout"`
+}
+
+// GameServerConfig is a game server; an empty Playlist or Region matches any.
+type GameServerConfig struct {
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Playlist string `json:"playlist"`
+	Region   string `json:"region"`
+	Capacity int    `json:"capacity"`
+}
+
+// ShopConfig controls the generated item shop. ResetTime is "HH:MM" UTC.
+type ShopConfig struct {
+	FeaturedSize int    `json:"featuredSize"`
+	DailySize    int    `json:"dailySize"`
+	ResetTime    string `json:"resetTime"`
+	FeaturedDays int    `json:"featuredDays"`
+	Seed         int64  `json:"seed"`
+
+	// Prices are in V-Bucks by backend type and rarity.
+	Prices map[string]map[string]int `json:"prices"`
+
+	// SetBundles item sets are sold as bundles at BundleDiscount percent off.
+	SetBundles     int          `json:"setBundles"`
+	BundleDiscount int          `json:"bundleDiscount"`
+	Bundles        []ShopBundle `json:"bundles"`
+}
+
+// ShopBundle is a fixed bundle; a zero Price means the discounted sum.
+type ShopBundle struct {
+	Name  string   `json:"name"`
+	Items []string `json:"items"`
+	Price int      `json:"price"`
+}
+
+// Settings holds the defaults until LoadConfig reads the config file.
+var Settings = Config{
+	Port:         "3551",
+	XmppPort:     "80",
+	XmppTcpPort:  "5222",
+	XmppCertFile: "config/xmpp.crt",
+	XmppKeyFile:  "config/xmpp.key",
+	Bot: BotConfig{
+		DisplayName: "NeoniteBot",
+		Status:      "Neonite Lobby Bot",
+		Character:   "CID_286_Athena_Commando_F_NeonCat",
+		Level:       69,
+	},
+	CloudStorageMaxSize: 4 << 20,
+	Matchmaking:         MatchmakingConfig{HeartbeatTimeout: 90},
+	Hotfixes: HotfixConfig{
+		Features: map[string]bool{
+			"bEnableGlobalChat": true,
+			"bDisableGifting":   false,
+		},
+	},
+	Shop: ShopConfig{
+		FeaturedSize: 4,
+		DailySize:    6,
+		ResetTime:    "00:00",
+		FeaturedDays: 1,
+		Prices: map[string]map[string]int{
+			"AthenaCharacter":       {"uncommon": 800, "rare": 1200, "epic": 1500, "legendary": 2000, "icon": 1500},
+			"AthenaBackpack":        {"uncommon": 200, "rare": 300, "epic": 400, "legendary": 500},
+			"AthenaPickaxe":         {"uncommon": 500, "rare": 800, "epic": 1200, "legendary": 1500},
+			"AthenaGlider":          {"uncommon": 500, "rare": 800, "epic": 1200, "legendary": 1500},
+			"AthenaDance":           {"uncommon": 200, "rare": 500, "epic": 800, "legendary": 800},
+			"AthenaItemWrap":        {"uncommon": 300, "rare": 500, "epic": 700, "legendary": 700},
+			"AthenaSkyDiveContrail": {"uncommon": 200, "rare": 300, "epic": 500, "legendary": 500},
+			"AthenaMusicPack":       {"rare": 200, "epic": 300},
+		},
+		SetBundles:     1,
+		BundleDiscount: 25,
+	},
+}
+
+// LoadConfig reads path over the defaults; a missing file is not an error.
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &Settings)
+}
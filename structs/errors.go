@@ -10,7 +10,7 @@ type APIError struct {
 }
 
 func (e APIError) Error() string {
-	panic("unimplemented")
+	return e.ErrorMessage
 }
 
 func (e APIError) With(detail string) APIError {
@@ -21,7 +21,29 @@ func (e APIError) With(detail string) APIError {
 var Errors = map[string]APIError{
 	"invalid_request":        {ErrorMessage: "invalid_request"},
 	"unsupported_grant_type": {ErrorMessage: "unsupported_grant_type"},
+	"invalid_grant":          {ErrorMessage: "invalid_grant"},
 	"server_error":           {ErrorMessage: "internal_server_error"},
+	"invalid_token":          {ErrorMessage: "invalid_token"},
+	"operation_forbidden":    {ErrorMessage: "operation_forbidden"},
+	"account_not_found":      {ErrorMessage: "account_not_found"},
+	"friendship_not_found":   {ErrorMessage: "friendship_not_found"},
+	"duplicate_friendship":   {ErrorMessage: "duplicate_friendship"},
+	"friend_request_sent":    {ErrorMessage: "friend_request_already_sent"},
+	"self_friend":            {ErrorMessage: "self_friend"},
+	"friend_blocked":         {ErrorMessage: "cannot_friend_due_to_target_settings"},
+	"party_not_found":        {ErrorMessage: "party_not_found"},
+	"member_not_found":       {ErrorMessage: "member_not_found"},
+	"party_full":             {ErrorMessage: "party_is_full"},
+	"stale_revision":         {ErrorMessage: "stale_revision"},
+	"party_forbidden":        {ErrorMessage: "party_change_forbidden"},
+	"party_join_forbidden":   {ErrorMessage: "party_join_forbidden"},
+	"not_found":              {ErrorMessage: "not_found"},
+	"loadout_not_found":      {ErrorMessage: "loadout_not_found"},
+	"keychain_conflict":      {ErrorMessage: "keychain_guid_conflict"},
+	"file_not_found":         {ErrorMessage: "file_not_found"},
+	"file_too_large":         {ErrorMessage: "file_too_large"},
+	"session_not_found":      {ErrorMessage: "session_not_found"},
+	"invalid_server":         {ErrorMessage: "invalid_server"},
 }
 
 func SendDetailedError(w http.ResponseWriter, err APIError, code int) {